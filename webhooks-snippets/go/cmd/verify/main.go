@@ -0,0 +1,169 @@
+// Command verify checks a captured OpenVidu Meet webhook against an API key
+// and reports which verification step fails.
+//
+// Usage:
+//
+//	verify -key KEY [-signature SIG -timestamp TS | -headers FILE] [-max-age D] [-seconds] [BODY_FILE]
+//
+// The body is read from BODY_FILE, or from standard input if it is omitted.
+// A headers file holds one "Name: value" header per line and may start with
+// the HTTP request line, as printed by curl -v or an HTTP dump.
+//
+// The exit status is 0 if the webhook verifies, 1 if it is rejected and 2
+// if it cannot be checked.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/webhook"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command with args and returns its exit status: 0 if the
+// webhook verifies, 1 if it is rejected and 2 if it cannot be checked.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	key := flags.String("key", os.Getenv("OPENVIDU_MEET_API_KEY"), "API key (defaults to $OPENVIDU_MEET_API_KEY)")
+	signature := flags.String("signature", "", "value of the x-signature header")
+	timestamp := flags.String("timestamp", "", "value of the x-timestamp header")
+	headersFile := flags.String("headers", "", "file with the captured request headers")
+	maxAge := flags.Duration("max-age", 0, "maximum accepted webhook age (defaults to the verifier default; raise it for old captures)")
+	seconds := flags.Bool("seconds", false, "the timestamp is in Unix seconds instead of milliseconds")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	var opts []webhook.Option
+	if *maxAge > 0 {
+		opts = append(opts, webhook.WithMaxAge(*maxAge))
+	}
+	unit := time.Millisecond
+	if *seconds {
+		opts = append(opts, webhook.WithTimestampUnit(webhook.UnitSeconds))
+		unit = time.Second
+	}
+
+	ok, err := verify(stdout, stdin, *key, *signature, *timestamp, *headersFile, opts, unit, flags.Arg(0))
+	switch {
+	case err != nil:
+		fmt.Fprintln(stderr, "verify:", err)
+		return 2
+	case !ok:
+		return 1
+	}
+	return 0
+}
+
+// verify prints a report on the webhook to w and returns whether it
+// verifies. An error means it could not be checked at all.
+func verify(w io.Writer, stdin io.Reader, key, signature, timestamp, headersFile string, opts []webhook.Option, unit time.Duration, bodyFile string) (bool, error) {
+	if key == "" {
+		return false, fmt.Errorf("-key is required")
+	}
+
+	headers := http.Header{}
+	if headersFile != "" {
+		var err error
+		if headers, err = readHeaders(headersFile); err != nil {
+			return false, err
+		}
+	}
+	if signature != "" {
+		headers.Set(webhook.DefaultSignatureHeader, signature)
+	}
+	if timestamp != "" {
+		headers.Set(webhook.DefaultTimestampHeader, timestamp)
+	}
+
+	body, err := readBody(stdin, bodyFile)
+	if err != nil {
+		return false, err
+	}
+
+	v, err := webhook.NewVerifier(key, opts...)
+	if err != nil {
+		return false, err
+	}
+
+	received := headers.Get(webhook.DefaultSignatureHeader)
+	ts := headers.Get(webhook.DefaultTimestampHeader)
+	fmt.Fprintf(w, "body:               %d bytes\n", len(body))
+	fmt.Fprintf(w, "received signature: %s\n", orMissing(received))
+	fmt.Fprintf(w, "timestamp:          %s%s\n", orMissing(ts), describeAge(ts, unit))
+	if ts != "" {
+		fmt.Fprintf(w, "expected signature: %s\n", v.ExpectedSignature(headers, body))
+	}
+
+	if err := v.Verify(body, headers); err != nil {
+		fmt.Fprintf(w, "result:             REJECTED (%s): %v\n", webhook.Reason(err), err)
+		return false, nil
+	}
+	fmt.Fprintln(w, "result:             OK")
+	return true, nil
+}
+
+func readBody(stdin io.Reader, path string) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// readHeaders parses a file of "Name: value" lines, skipping a leading HTTP
+// request line if there is one.
+func readHeaders(path string) (http.Header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	if line, err := r.Peek(64); err == nil || err == io.EOF {
+		if first, _, _ := strings.Cut(string(line), "\n"); strings.Contains(first, " HTTP/") {
+			if _, err := r.ReadString('\n'); err != nil && err != io.EOF {
+				return nil, err
+			}
+		}
+	}
+
+	mime, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return http.Header(mime), nil
+}
+
+func describeAge(ts string, unit time.Duration) string {
+	n, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ""
+	}
+	sent := time.Unix(0, n*int64(unit))
+	age := time.Since(sent).Round(time.Millisecond)
+	return fmt.Sprintf(" (%s, age %s)", sent.UTC().Format(time.RFC3339), age)
+}
+
+func orMissing(s string) string {
+	if s == "" {
+		return "(missing)"
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/webhook"
+)
+
+const (
+	testKey  = "test-api-key"
+	testBody = `{"event":"meetingStarted","creationDate":1700000000000,"data":{"roomId":"room-1","roomName":"Room 1"}}`
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	t.Setenv("OPENVIDU_MEET_API_KEY", "")
+	signature, ts := webhook.Sign(testKey, []byte(testBody), time.Now())
+	bodyFile := writeFile(t, "body.json", testBody)
+	headersFile := writeFile(t, "headers.txt", "POST /webhook HTTP/1.1\r\nx-signature: "+signature+"\r\nx-timestamp: "+ts+"\r\n\r\n")
+
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		want       int
+		wantStdout []string
+		wantStderr string
+	}{
+		{"valid", []string{"-key", testKey, "-signature", signature, "-timestamp", ts, bodyFile}, "", 0,
+			[]string{"received signature: " + signature, "expected signature: " + signature, "result:             OK"}, ""},
+		{"body from stdin", []string{"-key", testKey, "-signature", signature, "-timestamp", ts}, testBody, 0,
+			[]string{"result:             OK"}, ""},
+		{"headers file", []string{"-key", testKey, "-headers", headersFile, bodyFile}, "", 0,
+			[]string{"result:             OK"}, ""},
+		{"wrong key", []string{"-key", "other-key", "-signature", signature, "-timestamp", ts, bodyFile}, "", 1,
+			[]string{"result:             REJECTED (signature_mismatch)"}, ""},
+		{"missing signature", []string{"-key", testKey, bodyFile}, "", 1,
+			[]string{"received signature: (missing)", "timestamp:          (missing)", "REJECTED (missing_signature)"}, ""},
+		{"missing key", []string{bodyFile}, "", 2, nil, "verify: -key is required"},
+		{"missing body file", []string{"-key", testKey, filepath.Join(t.TempDir(), "missing.json")}, "", 2, nil, "verify: open"},
+		{"unknown flag", []string{"-bogus"}, "", 2, nil, "flag provided but not defined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if got := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr); got != tt.want {
+				t.Fatalf("run() = %d, want %d\nstdout:\n%s\nstderr:\n%s", got, tt.want, &stdout, &stderr)
+			}
+			for _, want := range tt.wantStdout {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("stdout does not contain %q:\n%s", want, &stdout)
+				}
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("stderr does not contain %q:\n%s", tt.wantStderr, &stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/webhook"
+)
+
+const (
+	defaultServerPort      = "5080"
+	defaultShutdownTimeout = 10 * time.Second
+)
+
+// Config is the webhook server configuration.
+type Config struct {
+	// APIKey is the OpenVidu Meet API key webhooks are signed with.
+	APIKey string
+	// AdditionalKeys are older keys still accepted during a rotation.
+	AdditionalKeys []string
+	// APIKeyFile is the file the keys were read from, if any.
+	APIKeyFile string
+	// Port is the port the server listens on.
+	Port string
+	// MaxAge is the maximum accepted webhook age.
+	MaxAge time.Duration
+	// ShutdownTimeout bounds how long in-flight requests, and then the
+	// events they queued, are drained on shutdown.
+	ShutdownTimeout time.Duration
+	// TLSCertFile and TLSKeyFile are the PEM certificate and key the server
+	// uses to serve HTTPS.
+	TLSCertFile string
+	TLSKeyFile  string
+	// PlainHTTP makes the server serve plain HTTP instead of HTTPS, which is
+	// only meant for local development or behind a TLS-terminating proxy.
+	// It must be set explicitly when there is no TLS certificate.
+	PlainHTTP bool
+	// DebugToken enables the POST /webhook/debug route, which explains why
+	// a webhook would be accepted or rejected, for requests carrying it as
+	// a bearer token. The route reveals expected signatures, so it is
+	// disabled when DebugToken is empty and should stay so in production.
+	DebugToken string
+}
+
+// TLS reports whether the server should serve HTTPS.
+func (c *Config) TLS() bool {
+	return c.TLSCertFile != ""
+}
+
+// LoadConfigFromEnv reads the configuration from these environment
+// variables:
+//
+//	OPENVIDU_MEET_API_KEY       API key
+//	OPENVIDU_MEET_API_KEY_FILE  file holding the API key, one key per line
+//	WEBHOOK_SERVER_PORT         listening port (default 5080)
+//	WEBHOOK_MAX_AGE             maximum webhook age as a Go duration (default 2m)
+//	WEBHOOK_SHUTDOWN_TIMEOUT    shutdown drain timeout as a Go duration (default 10s)
+//	WEBHOOK_TLS_CERT_FILE       PEM certificate to serve HTTPS with
+//	WEBHOOK_TLS_KEY_FILE        PEM private key of the certificate
+//	WEBHOOK_PLAIN_HTTP          "true" to serve plain HTTP instead of HTTPS
+//	WEBHOOK_DEBUG_TOKEN         bearer token enabling POST /webhook/debug
+//
+// One of OPENVIDU_MEET_API_KEY or OPENVIDU_MEET_API_KEY_FILE is required.
+// The file takes precedence and keeps the key out of the process
+// environment; keys after its first line are accepted as additional keys
+// during a rotation; the server rereads it on SIGHUP. The TLS certificate
+// and key must be set together, and are required unless WEBHOOK_PLAIN_HTTP
+// opts into plain HTTP, so that a missing certificate does not silently
+// downgrade the server.
+func LoadConfigFromEnv() (*Config, error) {
+	cfg := &Config{
+		APIKey:          os.Getenv("OPENVIDU_MEET_API_KEY"),
+		APIKeyFile:      os.Getenv("OPENVIDU_MEET_API_KEY_FILE"),
+		Port:            os.Getenv("WEBHOOK_SERVER_PORT"),
+		MaxAge:          webhook.DefaultMaxAge,
+		ShutdownTimeout: defaultShutdownTimeout,
+		TLSCertFile:     os.Getenv("WEBHOOK_TLS_CERT_FILE"),
+		TLSKeyFile:      os.Getenv("WEBHOOK_TLS_KEY_FILE"),
+		DebugToken:      os.Getenv("WEBHOOK_DEBUG_TOKEN"),
+	}
+	if cfg.APIKeyFile != "" {
+		keys, err := webhook.LoadKeysFromFile(cfg.APIKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.APIKey, cfg.AdditionalKeys = keys[0], keys[1:]
+	}
+	if cfg.APIKey == "" {
+		return nil, errors.New("OPENVIDU_MEET_API_KEY or OPENVIDU_MEET_API_KEY_FILE must be set")
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, errors.New("WEBHOOK_TLS_CERT_FILE and WEBHOOK_TLS_KEY_FILE must be set together")
+	}
+	if cfg.Port == "" {
+		cfg.Port = defaultServerPort
+	}
+
+	var err error
+	if cfg.PlainHTTP, err = boolFromEnv("WEBHOOK_PLAIN_HTTP"); err != nil {
+		return nil, err
+	}
+	switch {
+	case cfg.PlainHTTP && cfg.TLS():
+		return nil, errors.New("WEBHOOK_PLAIN_HTTP cannot be combined with WEBHOOK_TLS_CERT_FILE")
+	case !cfg.PlainHTTP && !cfg.TLS():
+		return nil, errors.New("WEBHOOK_TLS_CERT_FILE and WEBHOOK_TLS_KEY_FILE must be set, or WEBHOOK_PLAIN_HTTP=true to serve plain HTTP")
+	}
+	if cfg.MaxAge, err = durationFromEnv("WEBHOOK_MAX_AGE", cfg.MaxAge); err != nil {
+		return nil, err
+	}
+	if cfg.ShutdownTimeout, err = durationFromEnv("WEBHOOK_SHUTDOWN_TIMEOUT", cfg.ShutdownTimeout); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// boolFromEnv parses the boolean in the environment variable name,
+// returning false if it is unset.
+func boolFromEnv(name string) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return b, nil
+}
+
+// durationFromEnv parses the duration in the environment variable name,
+// returning def if it is unset.
+func durationFromEnv(name string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s: must be positive", name)
+	}
+	return d, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	t.Setenv("OPENVIDU_MEET_API_KEY", "secret")
+	t.Setenv("WEBHOOK_SERVER_PORT", "8080")
+	t.Setenv("WEBHOOK_MAX_AGE", "5m")
+	t.Setenv("WEBHOOK_PLAIN_HTTP", "true")
+
+	cfg, err := LoadConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIKey != "secret" || cfg.Port != "8080" || cfg.MaxAge != 5*time.Minute || !cfg.PlainHTTP {
+		t.Errorf("LoadConfigFromEnv() = %+v", cfg)
+	}
+	if cfg.ShutdownTimeout != defaultShutdownTimeout {
+		t.Errorf("ShutdownTimeout = %v, want %v", cfg.ShutdownTimeout, defaultShutdownTimeout)
+	}
+}
+
+func TestLoadConfigFromEnvKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-key")
+	if err := os.WriteFile(path, []byte("new-key\nold-key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OPENVIDU_MEET_API_KEY", "")
+	t.Setenv("OPENVIDU_MEET_API_KEY_FILE", path)
+	t.Setenv("WEBHOOK_TLS_CERT_FILE", "cert.pem")
+	t.Setenv("WEBHOOK_TLS_KEY_FILE", "key.pem")
+
+	cfg, err := LoadConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIKey != "new-key" || !slices.Equal(cfg.AdditionalKeys, []string{"old-key"}) {
+		t.Errorf("keys = %q, %q; want %q, [%q]", cfg.APIKey, cfg.AdditionalKeys, "new-key", "old-key")
+	}
+}
+
+func TestLoadConfigFromEnvErrors(t *testing.T) {
+	tests := map[string]map[string]string{
+		"missing key":         {"OPENVIDU_MEET_API_KEY": "", "WEBHOOK_PLAIN_HTTP": "true"},
+		"invalid max age":     {"OPENVIDU_MEET_API_KEY": "secret", "WEBHOOK_PLAIN_HTTP": "true", "WEBHOOK_MAX_AGE": "two minutes"},
+		"zero max age":        {"OPENVIDU_MEET_API_KEY": "secret", "WEBHOOK_PLAIN_HTTP": "true", "WEBHOOK_MAX_AGE": "0s"},
+		"tls cert only":       {"OPENVIDU_MEET_API_KEY": "secret", "WEBHOOK_TLS_CERT_FILE": "cert.pem"},
+		"no tls nor plain":    {"OPENVIDU_MEET_API_KEY": "secret"},
+		"plain http disabled": {"OPENVIDU_MEET_API_KEY": "secret", "WEBHOOK_PLAIN_HTTP": "false"},
+		"invalid plain http":  {"OPENVIDU_MEET_API_KEY": "secret", "WEBHOOK_PLAIN_HTTP": "yes please"},
+		"plain http with tls": {"OPENVIDU_MEET_API_KEY": "secret", "WEBHOOK_PLAIN_HTTP": "true", "WEBHOOK_TLS_CERT_FILE": "cert.pem", "WEBHOOK_TLS_KEY_FILE": "key.pem"},
+	}
+	for name, env := range tests {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range env {
+				t.Setenv(k, v)
+			}
+			if _, err := LoadConfigFromEnv(); err == nil {
+				t.Error("LoadConfigFromEnv() succeeded, want error")
+			}
+		})
+	}
+}
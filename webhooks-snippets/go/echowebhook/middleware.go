@@ -0,0 +1,121 @@
+// Package echowebhook verifies OpenVidu Meet webhooks in Echo applications.
+//
+// It depends only on the webhook package and Echo, so Echo applications do
+// not pull in gin.
+package echowebhook
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/webhook"
+)
+
+// Context keys under which EchoMiddleware and EchoPassthroughMiddleware
+// store their results.
+const (
+	// EventKey holds the parsed *webhook.WebhookEvent. It is not set by
+	// EchoPassthroughMiddleware.
+	EventKey = "openvidu-meet-webhook-event"
+	// RawBodyKey holds the verified request body as []byte, decompressed
+	// if it was sent gzip-encoded.
+	RawBodyKey = "openvidu-meet-webhook-body"
+)
+
+// EchoMiddleware returns an Echo middleware that reads and verifies the
+// request body with v and parses it into a webhook event. On success the
+// event and the raw body are stored in the context under EventKey and
+// RawBodyKey, and the request body is replaced with a reader over the raw
+// body, so that later handlers, such as ones calling c.Bind, can read it
+// again. On failure an *echo.HTTPError with the status given by
+// webhook.StatusCode is returned. Its message is an echo.Map holding the
+// error, as described by webhook.ErrorMessage, under "message" and the
+// request's correlation id (see webhook.AttachRequestID) under
+// "request_id". If v has a responder set with webhook.WithErrorResponder,
+// it answers the request instead and the middleware returns nil. Events
+// ignored with webhook.WithAllowedEventTypes are acknowledged with
+// v.WriteSuccess. Once the handler has run, its response is reported with
+// v.Complete: with webhook.WithResponseCache 2xx responses of handlers that
+// return nil are stored, and retries of the delivery are answered with
+// them, while handlers that return an error or answer with another status
+// release the delivery so that its retries are handled again.
+func EchoMiddleware(v *webhook.Verifier) echo.MiddlewareFunc {
+	return middleware(v, v.ReadRequest)
+}
+
+// EchoPassthroughMiddleware is like EchoMiddleware but does not parse the
+// verified body, for relays that forward webhooks untouched: handlers get
+// the body under RawBodyKey and from the request body, and no event is
+// stored. See webhook.PassthroughHandler for how this passthrough mode
+// differs from the dispatch mode of EchoMiddleware.
+func EchoPassthroughMiddleware(v *webhook.Verifier) echo.MiddlewareFunc {
+	return middleware(v, func(r *http.Request) (*webhook.WebhookEvent, []byte, error) {
+		body, err := v.ReadVerified(r)
+		return nil, body, err
+	})
+}
+
+// middleware returns an Echo middleware that reads requests with read.
+func middleware(v *webhook.Verifier, read func(*http.Request) (*webhook.WebhookEvent, []byte, error)) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.SetRequest(webhook.AttachRequestID(c.Request()))
+			event, body, err := read(c.Request())
+			if resp, ok := v.CachedResponse(c.Request(), err); ok {
+				resp.Write(c.Response())
+				return nil
+			}
+			if errors.Is(err, webhook.ErrEventIgnored) {
+				v.WriteSuccess(c.Response())
+				return nil
+			}
+			if err != nil && v.ErrorResponder() != nil {
+				v.WriteError(c.Response(), c.Request(), err)
+				return nil
+			}
+			if err != nil {
+				status := webhook.StatusCode(err)
+				return echo.NewHTTPError(status, echo.Map{
+					"message":    webhook.ErrorMessage(status, err),
+					"request_id": webhook.RequestIDFromContext(c.Request().Context()),
+				}).SetInternal(err)
+			}
+
+			req := c.Request()
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+			c.Set(RawBodyKey, body)
+			if event != nil {
+				c.Set(EventKey, event)
+			}
+			if v.ResponseCache() == nil {
+				if err := next(c); err != nil {
+					v.Release(req)
+					return err
+				}
+				v.Complete(req, &webhook.CachedResponse{Status: c.Response().Status})
+				return nil
+			}
+
+			rec := webhook.NewResponseRecorder(c.Response().Writer)
+			c.Response().Writer = rec
+			if err := next(c); err != nil {
+				v.Release(req)
+				return err
+			}
+			v.Complete(req, rec.Response())
+			return nil
+		}
+	}
+}
+
+// Event returns the event stored by EchoMiddleware, or nil if the
+// middleware did not run for this request.
+func Event(c echo.Context) *webhook.WebhookEvent {
+	event, _ := c.Get(EventKey).(*webhook.WebhookEvent)
+	return event
+}
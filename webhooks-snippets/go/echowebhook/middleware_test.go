@@ -0,0 +1,140 @@
+package echowebhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/webhook"
+)
+
+const (
+	testKey  = "test-api-key"
+	testBody = `{"event":"meetingStarted","creationDate":1700000000000,"data":{"roomId":"room-1","roomName":"Room 1"}}`
+)
+
+func signedRequest(t testing.TB, body string) *http.Request {
+	t.Helper()
+
+	signature, ts := webhook.Sign(testKey, []byte(body), time.Now())
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	req.Header.Set("x-timestamp", ts)
+	req.Header.Set("x-signature", signature)
+	return req
+}
+
+func newServer(t testing.TB, opts ...webhook.Option) *echo.Echo {
+	t.Helper()
+
+	v, err := webhook.NewVerifier(testKey, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.POST("/webhook", func(c echo.Context) error {
+		event := Event(c)
+		body := c.Get(RawBodyKey).([]byte)
+		if string(body) != testBody {
+			t.Errorf("raw body = %q, want %q", body, testBody)
+		}
+		return c.String(http.StatusOK, event.RoomID)
+	}, EchoMiddleware(v))
+	return e
+}
+
+func TestEchoMiddlewareAcceptsValidRequest(t *testing.T) {
+	e := newServer(t)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, signedRequest(t, testBody))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if got := rec.Body.String(); got != "room-1" {
+		t.Errorf("room id = %q, want %q", got, "room-1")
+	}
+}
+
+func TestEchoMiddlewareRejectsTamperedRequest(t *testing.T) {
+	e := newServer(t)
+
+	tampered := strings.Replace(testBody, "room-1", "room-2", 1)
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(tampered))
+	req.Header = signedRequest(t, testBody).Header
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestEchoMiddlewareRejectsMissingHeaders(t *testing.T) {
+	e := newServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(testBody))
+	req.Header.Set("x-request-id", "req-1")
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	var resp struct {
+		Message   string `json:"message"`
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Message == "" || resp.RequestID != "req-1" {
+		t.Errorf("body = %s, want the error and request id", rec.Body)
+	}
+}
+
+func TestEchoMiddlewareAcknowledgesIgnoredEvent(t *testing.T) {
+	e := newServer(t, webhook.WithAllowedEventTypes(webhook.EventRecordingReady))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, signedRequest(t, testBody))
+
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Fatalf("response = %d %q, want an empty %d without calling the handler", rec.Code, rec.Body, http.StatusOK)
+	}
+}
+
+func TestEchoMiddlewareBodyRereadable(t *testing.T) {
+	v, err := webhook.NewVerifier(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.POST("/webhook", func(c echo.Context) error {
+		var payload struct {
+			Event string `json:"event"`
+			Data  struct {
+				RoomID string `json:"roomId"`
+			} `json:"data"`
+		}
+		if err := c.Bind(&payload); err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, payload.Event+" "+payload.Data.RoomID)
+	}, EchoMiddleware(v))
+
+	req := signedRequest(t, testBody)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "meetingStarted room-1" {
+		t.Errorf("response = %d %q, want the body bound downstream", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,114 @@
+// Package ginwebhook verifies OpenVidu Meet webhooks in gin applications.
+package ginwebhook
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/webhook"
+)
+
+// Context keys under which VerifyMiddleware and PassthroughMiddleware store
+// their results.
+const (
+	// EventKey holds the parsed *webhook.WebhookEvent. It is not set by
+	// PassthroughMiddleware.
+	EventKey = "openvidu-meet-webhook-event"
+	// RawBodyKey holds the verified request body as []byte, decompressed
+	// if it was sent gzip-encoded.
+	RawBodyKey = "openvidu-meet-webhook-body"
+)
+
+// VerifyMiddleware returns a gin middleware that reads and verifies the
+// request body with v and parses it into a webhook event. On success the
+// event and the raw body are stored in the context under EventKey and
+// RawBodyKey, and c.Request.Body is replaced with a reader over the raw
+// body, so that later handlers and middleware, such as ShouldBindJSON or
+// body loggers, can read it again. On failure the request is aborted and
+// answered with v.WriteError, by default a JSON error that includes the
+// request's correlation id (see webhook.AttachRequestID). Once the handlers
+// that follow have run, their response is reported with v.Complete: with
+// webhook.WithResponseCache 2xx responses are stored, and retries of the
+// delivery are answered with them, while other statuses release the
+// delivery so that its retries are handled again.
+func VerifyMiddleware(v *webhook.Verifier) gin.HandlerFunc {
+	return middleware(v, v.ReadRequest)
+}
+
+// PassthroughMiddleware is like VerifyMiddleware but does not parse the
+// verified body, for relays that forward webhooks untouched: the handlers
+// that follow get the body under RawBodyKey and from c.Request.Body, and no
+// event is stored. See webhook.PassthroughHandler for how this passthrough
+// mode differs from the dispatch mode of VerifyMiddleware.
+func PassthroughMiddleware(v *webhook.Verifier) gin.HandlerFunc {
+	return middleware(v, func(r *http.Request) (*webhook.WebhookEvent, []byte, error) {
+		body, err := v.ReadVerified(r)
+		return nil, body, err
+	})
+}
+
+// middleware returns a gin middleware that reads requests with read.
+func middleware(v *webhook.Verifier, read func(*http.Request) (*webhook.WebhookEvent, []byte, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = webhook.AttachRequestID(c.Request)
+		event, body, err := read(c.Request)
+		if resp, ok := v.CachedResponse(c.Request, err); ok {
+			resp.Write(c.Writer)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			v.WriteError(c.Writer, c.Request, err)
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+		c.Set(RawBodyKey, body)
+		if event != nil {
+			c.Set(EventKey, event)
+		}
+		if v.ResponseCache() == nil {
+			c.Next()
+			v.Complete(c.Request, &webhook.CachedResponse{Status: c.Writer.Status()})
+			return
+		}
+
+		rec := &recorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+		header := rec.Header().Clone()
+		header.Del("Content-Length")
+		v.Complete(c.Request, &webhook.CachedResponse{
+			Status: rec.Status(),
+			Header: header,
+			Body:   bytes.Clone(rec.body.Bytes()),
+		})
+	}
+}
+
+// recorder records the body written through a gin.ResponseWriter.
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Event returns the event stored by VerifyMiddleware. It panics if the
+// middleware did not run for this request.
+func Event(c *gin.Context) *webhook.WebhookEvent {
+	return c.MustGet(EventKey).(*webhook.WebhookEvent)
+}
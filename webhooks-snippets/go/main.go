@@ -1,76 +1,184 @@
 package main
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
+	"context"
 	"crypto/subtle"
-	"encoding/hex"
-	"io"
-	"log"
+	"crypto/tls"
+	"errors"
+	"log/slog"
 	"net/http"
-	"strconv"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
-)
 
-const (
-	serverPort         = "5080"
-	maxWebhookAge      = 120 * 1000 // 2 minutes in milliseconds
-	openviduMeetApiKey = "meet-api-key"
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/ginwebhook"
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/webhook"
 )
 
 func main() {
-	router := gin.Default()
-	router.POST("/webhook", handleWebhook)
-	router.Run(":" + serverPort)
-}
+	logger := slog.New(webhook.NewRequestIDHandler(slog.NewJSONHandler(os.Stdout, nil)))
 
-func handleWebhook(c *gin.Context) {
-	bodyBytes, err := io.ReadAll(c.Request.Body)
+	cfg, err := LoadConfigFromEnv()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
-		return
+		logger.Error("Invalid configuration", "error", err)
+		os.Exit(1)
 	}
 
-	if !isWebhookEventValid(bodyBytes, c.Request.Header) {
-		log.Println("Invalid webhook signature")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
-		return
+	verifier, err := webhook.NewVerifier(cfg.APIKey,
+		webhook.WithAdditionalKeys(cfg.AdditionalKeys...),
+		webhook.WithMaxAge(cfg.MaxAge),
+		webhook.WithLogger(logger))
+	if err != nil {
+		logger.Error("Invalid webhook configuration", "error", err)
+		os.Exit(1)
 	}
 
-	log.Println("Webhook received:", string(bodyBytes))
-	c.Status(http.StatusOK)
-}
+	dispatcher := &webhook.Dispatcher{}
+	dispatcher.SetLogger(logger)
+	dispatcher.On(webhook.EventMeetingStarted, func(ctx context.Context, event *webhook.WebhookEvent) error {
+		logger.InfoContext(ctx, "Meeting started", "room_id", event.RoomID)
+		return nil
+	})
+	dispatcher.On(webhook.EventRecordingEnded, func(ctx context.Context, event *webhook.WebhookEvent) error {
+		logger.InfoContext(ctx, "Recording ended", "recording_id", event.Recording.RecordingID, "status", event.Recording.Status)
+		return nil
+	})
+
+	pool := webhook.NewWorkerPool(4, 100, webhook.WithQueueLogger(logger))
+	pool.Handle(dispatcher.Dispatch)
 
-func isWebhookEventValid(bodyBytes []byte, headers http.Header) bool {
-	signature := headers.Get("x-signature")
-	tsStr := headers.Get("x-timestamp")
-	if signature == "" || tsStr == "" {
-		return false
+	router := gin.Default()
+	router.GET("/healthz", handleHealth)
+	router.GET("/readyz", handleReady(verifier))
+	router.POST("/webhook", ginwebhook.VerifyMiddleware(verifier), handleWebhook(verifier, pool))
+	if cfg.DebugToken != "" {
+		logger.Warn("Webhook debug endpoint enabled; it reveals expected signatures", "path", "/webhook/debug")
+		router.POST("/webhook/debug", requireToken(cfg.DebugToken), gin.WrapF(webhook.DebugHandler(verifier)))
 	}
 
-	timestamp, err := strconv.ParseInt(tsStr, 10, 64)
-	if err != nil {
-		return false
+	server := &http.Server{
+		Addr:      ":" + cfg.Port,
+		Handler:   router,
+		TLSConfig: tlsConfig(),
 	}
 
-	current := time.Now().UnixMilli()
-	diffTime := current - timestamp
-	if diffTime >= maxWebhookAge {
-		return false
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go reloadKeys(hup, cfg.APIKeyFile, verifier, logger)
+
+	if cfg.PlainHTTP {
+		logger.Warn("Serving plain HTTP; only use it for local development or behind a TLS-terminating proxy")
 	}
+	go func() {
+		logger.Info("Webhook server listening", "addr", server.Addr, "tls", cfg.TLS())
+		var err error
+		if cfg.TLS() {
+			err = server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Webhook server failed", "error", err)
+			os.Exit(1)
+		}
+	}()
 
-	signedPayload := tsStr + "." + string(bodyBytes)
+	<-ctx.Done()
+	stop()
+	logger.Info("Shutting down webhook server", "timeout", cfg.ShutdownTimeout.String())
 
-	mac := hmac.New(sha256.New, []byte(openviduMeetApiKey))
-	mac.Write([]byte(signedPayload))
-	expected := mac.Sum(nil)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Webhook server did not shut down cleanly", "error", err)
+	}
+	// Events already acknowledged must be processed before exiting, within
+	// what is left of the shutdown timeout.
+	if err := pool.Close(shutdownCtx); err != nil {
+		logger.Error("Webhook queue did not drain", "error", err)
+	}
+}
 
-	actual, err := hex.DecodeString(signature)
-	if err != nil {
-		return false
+// reloadKeys reloads the API keys from file every time a signal arrives on
+// signals, so keys can be rotated without a restart. The current keys are
+// kept if the file cannot be read.
+func reloadKeys(signals <-chan os.Signal, file string, verifier *webhook.Verifier, logger *slog.Logger) {
+	for range signals {
+		if file == "" {
+			logger.Warn("Ignoring SIGHUP: API keys are not read from a file")
+			continue
+		}
+		keys, err := webhook.LoadKeysFromFile(file)
+		if err == nil {
+			err = verifier.SetKeys(keys)
+		}
+		if err != nil {
+			logger.Error("Failed to reload API keys", "file", file, "error", err)
+			continue
+		}
+		logger.Info("Reloaded API keys", "file", file, "keys", len(keys))
 	}
+}
 
-	return subtle.ConstantTimeCompare(expected, actual) == 1
+// tlsConfig requires TLS 1.2 or later and, for TLS 1.2, forward-secret AEAD
+// cipher suites only. TLS 1.3 suites are not configurable and are all
+// considered secure.
+func tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+	}
+}
+
+// handleWebhook queues verified events for processing and acknowledges them
+// right away, so slow handlers do not make OpenVidu Meet retry the delivery.
+func handleWebhook(verifier *webhook.Verifier, pool *webhook.WorkerPool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := pool.Submit(c.Request.Context(), ginwebhook.Event(c)); err != nil {
+			verifier.WriteError(c.Writer, c.Request, err)
+			return
+		}
+
+		verifier.WriteSuccess(c.Writer)
+	}
+}
+
+// requireToken aborts requests that do not carry token as a bearer token.
+func requireToken(token string) gin.HandlerFunc {
+	want := []byte("Bearer " + token)
+	return func(c *gin.Context) {
+		got := []byte(c.GetHeader("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid debug token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func handleHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func handleReady(verifier *webhook.Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := verifier.Ready(c.Request.Context()); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "ready"})
+	}
 }
@@ -0,0 +1,130 @@
+// Package otelwebhook traces OpenVidu Meet webhook requests with
+// OpenTelemetry.
+package otelwebhook
+
+import (
+	"errors"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/webhook"
+)
+
+const instrumentationName = "github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/otelwebhook"
+
+// Span attribute keys.
+const (
+	EventTypeKey = attribute.Key("openvidu_meet.webhook.event")
+	RoomIDKey    = attribute.Key("openvidu_meet.webhook.room_id")
+	OutcomeKey   = attribute.Key("openvidu_meet.webhook.outcome")
+)
+
+type config struct {
+	provider   trace.TracerProvider
+	propagator propagation.TextMapPropagator
+}
+
+// Option configures Handler.
+type Option func(*config)
+
+// WithTracerProvider sets the provider used to create spans. The default is
+// the global provider from otel.GetTracerProvider, which creates no-op
+// spans until an SDK is installed.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.provider = tp
+	}
+}
+
+// WithPropagator sets how the incoming trace context is read from the
+// request headers. The default is the global propagator from
+// otel.GetTextMapPropagator.
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagator = p
+	}
+}
+
+// Handler is like webhook.DispatchHandler but traces each request. It
+// starts a server span that continues any trace context sent with the
+// request, with a "verify" child span around verification and parsing and a
+// "handle" child span around handler. Spans are marked as errors when the
+// webhook is rejected or the handler fails. Retries of handled deliveries
+// are answered with the response stored with webhook.WithResponseCache, as
+// by webhook.Handler, and marked with the "cached" outcome.
+func Handler(v *webhook.Verifier, handler webhook.HandlerFunc, opts ...Option) http.Handler {
+	c := config{
+		provider:   otel.GetTracerProvider(),
+		propagator: otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	tracer := c.provider.Tracer(instrumentationName)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = webhook.AttachRequestID(r)
+		ctx := c.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, "webhook", trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		verifyCtx, verifySpan := tracer.Start(ctx, "verify")
+		event, _, err := v.ReadRequest(r.WithContext(verifyCtx))
+		if errors.Is(err, webhook.ErrEventIgnored) {
+			verifySpan.SetAttributes(OutcomeKey.String("ignored"))
+			verifySpan.End()
+			span.SetAttributes(OutcomeKey.String("ignored"))
+			v.WriteSuccess(w)
+			return
+		}
+		if err != nil {
+			reason := webhook.Reason(err)
+			verifySpan.SetAttributes(OutcomeKey.String(reason))
+			verifySpan.RecordError(err)
+			verifySpan.SetStatus(codes.Error, reason)
+			verifySpan.End()
+
+			if resp, ok := v.CachedResponse(r, err); ok {
+				span.SetAttributes(OutcomeKey.String("cached"))
+				resp.Write(w)
+				return
+			}
+			span.SetAttributes(OutcomeKey.String(reason))
+			span.SetStatus(codes.Error, "webhook rejected")
+			v.WriteError(w, r, err)
+			return
+		}
+		attrs := []attribute.KeyValue{EventTypeKey.String(string(event.Type))}
+		if event.RoomID != "" {
+			attrs = append(attrs, RoomIDKey.String(event.RoomID))
+		}
+		verifySpan.SetAttributes(OutcomeKey.String("accepted"))
+		verifySpan.End()
+		span.SetAttributes(attrs...)
+
+		rec := webhook.NewResponseRecorder(w)
+		w = rec
+		defer func() { v.Complete(r, rec.Response()) }()
+
+		handleCtx, handleSpan := tracer.Start(ctx, "handle", trace.WithAttributes(attrs...))
+		err = v.RunHandler(handleCtx, event, handler)
+		if err != nil {
+			handleSpan.RecordError(err)
+			handleSpan.SetStatus(codes.Error, "handler failed")
+			handleSpan.End()
+
+			span.SetAttributes(OutcomeKey.String("handler_error"))
+			span.SetStatus(codes.Error, "handler failed")
+			v.WriteError(w, r, err)
+			return
+		}
+		handleSpan.End()
+		span.SetAttributes(OutcomeKey.String("accepted"))
+		v.WriteSuccess(w)
+	})
+}
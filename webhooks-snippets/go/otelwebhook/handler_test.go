@@ -0,0 +1,183 @@
+package otelwebhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/webhook"
+)
+
+const (
+	testKey  = "test-api-key"
+	testBody = `{"event":"meetingStarted","creationDate":1700000000000,"data":{"roomId":"room-1","roomName":"Room 1"}}`
+)
+
+func signedRequest(body string) *http.Request {
+	signature, ts := webhook.Sign(testKey, []byte(body), time.Now())
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	req.Header.Set("x-timestamp", ts)
+	req.Header.Set("x-signature", signature)
+	return req
+}
+
+func newHandler(t *testing.T, handler webhook.HandlerFunc, opts ...webhook.Option) (http.Handler, *tracetest.SpanRecorder) {
+	t.Helper()
+
+	v, err := webhook.NewVerifier(testKey, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	h := Handler(v, handler, WithTracerProvider(tp), WithPropagator(propagation.TraceContext{}))
+	return h, recorder
+}
+
+func spansByName(recorder *tracetest.SpanRecorder) map[string]sdktrace.ReadOnlySpan {
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	return spans
+}
+
+func TestHandlerTracesAcceptedWebhook(t *testing.T) {
+	h, recorder := newHandler(t, func(ctx context.Context, event *webhook.WebhookEvent) error {
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			t.Error("handler context carries no span")
+		}
+		return nil
+	})
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := signedRequest(testBody)
+	req.Header.Set("traceparent", parent)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	spans := spansByName(recorder)
+	root, verify, handle := spans["webhook"], spans["verify"], spans["handle"]
+	if root == nil || verify == nil || handle == nil {
+		t.Fatalf("spans = %v, want webhook, verify and handle", spans)
+	}
+	if got := root.Parent().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("root span trace id = %s, want the incoming one", got)
+	}
+	for _, child := range []sdktrace.ReadOnlySpan{verify, handle} {
+		if child.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("%s span is not a child of the root span", child.Name())
+		}
+	}
+	if got := attr(root, EventTypeKey); got != "meetingStarted" {
+		t.Errorf("event attribute = %q, want %q", got, "meetingStarted")
+	}
+	if got := attr(verify, OutcomeKey); got != "accepted" {
+		t.Errorf("verify outcome = %q, want %q", got, "accepted")
+	}
+}
+
+func TestHandlerTracesRejectedWebhook(t *testing.T) {
+	h, recorder := newHandler(t, func(ctx context.Context, event *webhook.WebhookEvent) error {
+		t.Error("handler called for rejected webhook")
+		return nil
+	})
+
+	req := signedRequest(testBody)
+	req.Header.Set("x-signature", strings.Repeat("0", 64))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	spans := spansByName(recorder)
+	if _, ok := spans["handle"]; ok {
+		t.Error("handle span recorded for rejected webhook")
+	}
+	verify := spans["verify"]
+	if verify == nil {
+		t.Fatal("no verify span recorded")
+	}
+	if verify.Status().Code != codes.Error {
+		t.Errorf("verify span status = %v, want Error", verify.Status().Code)
+	}
+	if got := attr(verify, OutcomeKey); got != "signature_mismatch" {
+		t.Errorf("verify outcome = %q, want %q", got, "signature_mismatch")
+	}
+}
+
+func TestHandlerTracesHandlerError(t *testing.T) {
+	h, recorder := newHandler(t, func(ctx context.Context, event *webhook.WebhookEvent) error {
+		return errors.New("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, signedRequest(testBody))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+
+	handle := spansByName(recorder)["handle"]
+	if handle == nil || handle.Status().Code != codes.Error {
+		t.Fatal("handle span missing or not marked as error")
+	}
+}
+
+func TestHandlerAnswersRetriesFromResponseCache(t *testing.T) {
+	replay := webhook.NewMemoryReplayStore(time.Minute)
+	defer replay.Close()
+	responses := webhook.NewMemoryResponseCache(time.Minute)
+	defer responses.Close()
+
+	var calls int
+	h, recorder := newHandler(t, func(ctx context.Context, event *webhook.WebhookEvent) error {
+		calls++
+		return nil
+	}, webhook.WithReplayCache(replay), webhook.WithResponseCache(responses),
+		webhook.WithSuccessResponse([]byte(`{"ack":true}`), "application/json"))
+
+	req := signedRequest(testBody)
+	for i := range 2 {
+		retry := req.Clone(req.Context())
+		retry.Body = io.NopCloser(strings.NewReader(testBody))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, retry)
+		if rec.Code != http.StatusOK || rec.Body.String() != `{"ack":true}` {
+			t.Errorf("delivery %d: response = %d %q, want the acknowledgment", i, rec.Code, rec.Body)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+	if got := attr(spansByName(recorder)["webhook"], OutcomeKey); got != "cached" {
+		t.Errorf("retry outcome = %q, want %q", got, "cached")
+	}
+}
+
+func attr(s sdktrace.ReadOnlySpan, key attribute.Key) string {
+	for _, kv := range s.Attributes() {
+		if kv.Key == key {
+			return kv.Value.AsString()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,166 @@
+// Package promwebhook exports OpenVidu Meet webhook metrics to Prometheus.
+package promwebhook
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/webhook"
+)
+
+const namespace = "openvidu_meet_webhook"
+
+// Metrics implements webhook.Metrics with Prometheus collectors.
+type Metrics struct {
+	received prometheus.Counter
+	rejected *prometheus.CounterVec
+	latency  prometheus.Histogram
+	overflow *prometheus.CounterVec
+	verify   *prometheus.HistogramVec
+	size     *prometheus.HistogramVec
+	drift    *prometheus.HistogramVec
+	panics   *prometheus.CounterVec
+	lastSeen *prometheus.GaugeVec
+	refresh  prometheus.Counter
+	ignored  *prometheus.CounterVec
+}
+
+var (
+	_ webhook.Metrics             = (*Metrics)(nil)
+	_ webhook.QueueMetrics        = (*Metrics)(nil)
+	_ webhook.VerificationMetrics = (*Metrics)(nil)
+	_ webhook.DriftMetrics        = (*Metrics)(nil)
+	_ webhook.PanicMetrics        = (*Metrics)(nil)
+	_ webhook.LastSeenMetrics     = (*Metrics)(nil)
+	_ webhook.KeyRefreshMetrics   = (*Metrics)(nil)
+	_ webhook.IgnoredMetrics      = (*Metrics)(nil)
+)
+
+// NewMetrics creates the webhook collectors and registers them with reg.
+// Pass the result to webhook.WithMetrics, to webhook.WithQueueMetrics
+// when using a WorkerPool or EventStream, and to
+// webhook.WithRemoteKeyMetrics when using a webhook.RemoteKeyProvider.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		received: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Webhook requests received.",
+		}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rejected_total",
+			Help:      "Webhook requests rejected, by reason.",
+		}, []string{"reason"}),
+		latency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "processing_seconds",
+			Help:      "Time spent reading, verifying and parsing webhook requests.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+		}),
+		overflow: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "queue_overflow_total",
+			Help:      "Events offered to a full processing queue, by overflow policy.",
+		}, []string{"policy"}),
+		verify: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "verification_seconds",
+			Help:      "Time spent computing signatures over and parsing webhook bodies, by event type.",
+			Buckets:   prometheus.ExponentialBuckets(0.00001, 4, 8),
+		}, []string{"event"}),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "body_size_bytes",
+			Help:      "Size of webhook bodies, by event type.",
+			Buckets:   prometheus.ExponentialBuckets(256, 4, 8),
+		}, []string{"event"}),
+		drift: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "clock_drift_seconds",
+			Help:      "Local time minus the timestamp of verified webhooks, by tenant. Negative values are timestamps ahead of the local clock.",
+			// The default max age is two minutes and the default clock
+			// skew five seconds.
+			Buckets: []float64{-5, -1, -0.25, 0, 0.25, 1, 5, 15, 30, 60, 120},
+		}, []string{"tenant"}),
+		panics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "handler_panics_total",
+			Help:      "Panics recovered in webhook handlers, by event type.",
+		}, []string{"event"}),
+		lastSeen: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_seen_timestamp_seconds",
+			Help:      "Unix time of the last verified webhook, by tenant. Alert on time() minus this value to catch sources that stopped sending webhooks.",
+		}, []string{"tenant"}),
+		refresh: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "key_refresh_failures_total",
+			Help:      "Failed attempts to refresh the keys of a webhook.RemoteKeyProvider.",
+		}),
+		ignored: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ignored_total",
+			Help:      "Verified webhooks ignored because their event type is not allowed, by event type.",
+		}, []string{"event"}),
+	}
+
+	for _, c := range []prometheus.Collector{m.received, m.rejected, m.latency, m.overflow, m.verify, m.size, m.drift, m.panics, m.lastSeen, m.refresh, m.ignored} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// IncReceived implements webhook.Metrics.
+func (m *Metrics) IncReceived() {
+	m.received.Inc()
+}
+
+// IncRejected implements webhook.Metrics.
+func (m *Metrics) IncRejected(reason string) {
+	m.rejected.WithLabelValues(reason).Inc()
+}
+
+// ObserveLatency implements webhook.Metrics.
+func (m *Metrics) ObserveLatency(d time.Duration) {
+	m.latency.Observe(d.Seconds())
+}
+
+// IncOverflow implements webhook.QueueMetrics.
+func (m *Metrics) IncOverflow(policy webhook.OverflowPolicy) {
+	m.overflow.WithLabelValues(policy.String()).Inc()
+}
+
+// ObserveVerification implements webhook.VerificationMetrics.
+func (m *Metrics) ObserveVerification(eventType string, d time.Duration, size int) {
+	m.verify.WithLabelValues(eventType).Observe(d.Seconds())
+	m.size.WithLabelValues(eventType).Observe(float64(size))
+}
+
+// ObserveDrift implements webhook.DriftMetrics.
+func (m *Metrics) ObserveDrift(tenant string, drift time.Duration) {
+	m.drift.WithLabelValues(tenant).Observe(drift.Seconds())
+}
+
+// IncHandlerPanic implements webhook.PanicMetrics.
+func (m *Metrics) IncHandlerPanic(eventType string) {
+	m.panics.WithLabelValues(eventType).Inc()
+}
+
+// SetLastSeen implements webhook.LastSeenMetrics.
+func (m *Metrics) SetLastSeen(tenant string, t time.Time) {
+	m.lastSeen.WithLabelValues(tenant).Set(float64(t.UnixNano()) / 1e9)
+}
+
+// IncKeyRefreshFailure implements webhook.KeyRefreshMetrics.
+func (m *Metrics) IncKeyRefreshFailure() {
+	m.refresh.Inc()
+}
+
+// IncIgnored implements webhook.IgnoredMetrics.
+func (m *Metrics) IncIgnored(eventType string) {
+	m.ignored.WithLabelValues(eventType).Inc()
+}
@@ -0,0 +1,62 @@
+package promwebhook
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/webhook"
+)
+
+const testKey = "test-api-key"
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := NewMetrics(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := webhook.NewVerifier(testKey, webhook.WithMetrics(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"event":"meetingStarted","creationDate":1700000000000,"data":{"roomId":"room-1"}}`)
+	for _, key := range []string{testKey, "other-key"} {
+		signature, timestamp := webhook.Sign(key, body, time.Now())
+		req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+		req.Header.Set("x-signature", signature)
+		req.Header.Set("x-timestamp", timestamp)
+		v.ReadRequest(req)
+	}
+
+	if got := testutil.ToFloat64(m.received); got != 2 {
+		t.Errorf("requests_total = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.rejected.WithLabelValues("signature_mismatch")); got != 1 {
+		t.Errorf(`rejected_total{reason="signature_mismatch"} = %v, want 1`, got)
+	}
+	if got := testutil.CollectAndCount(m.latency); got != 1 {
+		t.Errorf("processing_seconds series = %d, want 1", got)
+	}
+	// The rejected request is not parsed, so it is not labeled by type.
+	if got := testutil.CollectAndCount(m.verify); got != 2 {
+		t.Errorf("verification_seconds series = %d, want 2", got)
+	}
+	if got := testutil.CollectAndCount(m.size, "openvidu_meet_webhook_body_size_bytes"); got != 2 {
+		t.Errorf("body_size_bytes series = %d, want 2", got)
+	}
+	// Only the verified request reports drift.
+	if got := testutil.CollectAndCount(m.drift); got != 1 {
+		t.Errorf("clock_drift_seconds series = %d, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.lastSeen.WithLabelValues("")); got == 0 {
+		t.Error("last_seen_timestamp_seconds not set for the verified request")
+	}
+}
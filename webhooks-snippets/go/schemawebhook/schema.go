@@ -0,0 +1,84 @@
+// Package schemawebhook validates OpenVidu Meet webhook bodies against a
+// JSON Schema, for stricter checks of custom fields than webhook.ParseEvent
+// makes.
+package schemawebhook
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/webhook"
+)
+
+var printer = message.NewPrinter(language.English)
+
+// WithSchema loads the JSON Schema at path and rejects verified webhooks
+// whose body does not conform to it with a *webhook.PayloadError naming the
+// first offending field. The schema is compiled once, when the Verifier is
+// created.
+func WithSchema(path string) webhook.Option {
+	return func(v *webhook.Verifier) error {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("schemawebhook: %w", err)
+		}
+		schema, err := jsonschema.NewCompiler().Compile(abs)
+		if err != nil {
+			return fmt.Errorf("schemawebhook: loading %s: %w", path, err)
+		}
+		return webhook.WithEventValidator(Validator(schema))(v)
+	}
+}
+
+// Validator returns a webhook.EventValidator that checks bodies against
+// schema.
+func Validator(schema *jsonschema.Schema) webhook.EventValidator {
+	return func(event *webhook.WebhookEvent, body []byte) error {
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+
+		var verr *jsonschema.ValidationError
+		if err := schema.Validate(doc); errors.As(err, &verr) {
+			leaf := firstLeaf(verr)
+			location, problem := leaf.InstanceLocation, leaf.ErrorKind.LocalizedString(printer)
+			if required, ok := leaf.ErrorKind.(*kind.Required); ok && len(required.Missing) > 0 {
+				location, problem = append(slices.Clone(location), required.Missing[0]), "missing"
+			}
+			return &webhook.PayloadError{
+				Event:   event.Type,
+				Field:   fieldPath(location),
+				Problem: problem,
+			}
+		} else if err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// firstLeaf returns the first error in e's tree that has no causes, which
+// is the most specific description of what is wrong.
+func firstLeaf(e *jsonschema.ValidationError) *jsonschema.ValidationError {
+	for len(e.Causes) > 0 {
+		e = e.Causes[0]
+	}
+	return e
+}
+
+func fieldPath(location []string) string {
+	if len(location) == 0 {
+		return "(root)"
+	}
+	return strings.Join(location, ".")
+}
@@ -0,0 +1,89 @@
+package schemawebhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/webhook"
+)
+
+const testKey = "test-api-key"
+
+const testSchema = `{
+	"type": "object",
+	"required": ["event", "data"],
+	"properties": {
+		"data": {
+			"type": "object",
+			"required": ["roomId", "tenant"],
+			"properties": {
+				"tenant": {"type": "string", "minLength": 1}
+			}
+		}
+	}
+}`
+
+func signedRequest(body string) *http.Request {
+	signature, ts := webhook.Sign(testKey, []byte(body), time.Now())
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	req.Header.Set("x-timestamp", ts)
+	req.Header.Set("x-signature", signature)
+	return req
+}
+
+func TestWithSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(testSchema), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := webhook.NewVerifier(testKey, WithSchema(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		wantField string
+	}{
+		{"valid", `{"event":"meetingStarted","data":{"roomId":"room-1","tenant":"acme"}}`, ""},
+		{"missing field", `{"event":"meetingStarted","data":{"roomId":"room-1"}}`, "data.tenant"},
+		{"wrong type", `{"event":"meetingStarted","data":{"roomId":"room-1","tenant":7}}`, "data.tenant"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := v.ReadRequest(signedRequest(tt.body))
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("ReadRequest() = %v, want nil", err)
+				}
+				return
+			}
+
+			var pe *webhook.PayloadError
+			if !errors.As(err, &pe) {
+				t.Fatalf("ReadRequest() = %v, want a *webhook.PayloadError", err)
+			}
+			if pe.Field != tt.wantField {
+				t.Errorf("Field = %q, want %q", pe.Field, tt.wantField)
+			}
+			if !errors.Is(err, webhook.ErrInvalidPayload) {
+				t.Errorf("error does not match ErrInvalidPayload")
+			}
+		})
+	}
+}
+
+func TestWithSchemaMissingFile(t *testing.T) {
+	if _, err := webhook.NewVerifier(testKey, WithSchema("does-not-exist.json")); err == nil {
+		t.Fatal("NewVerifier() with missing schema succeeded, want error")
+	}
+}
@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIP returns the address of the client that sent r. Without trusted
+// proxies it is the IP part of r.RemoteAddr. With n trusted proxies it is
+// the n-th X-Forwarded-For entry from the right, or "" if the header has
+// fewer entries than expected.
+func (v *Verifier) clientIP(r *http.Request) string {
+	if v.trustedProxies == 0 {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	if len(hops) < v.trustedProxies {
+		return ""
+	}
+	return hops[len(hops)-v.trustedProxies]
+}
+
+// addressAllowed reports whether ip falls in one of the WithAllowedCIDRs
+// ranges.
+func (v *Verifier) addressAllowed(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range v.allowedNets {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,13 @@
+package webhook
+
+import "time"
+
+// Clock tells a Verifier the current time. It exists so tests can check
+// time-dependent behavior, such as the webhook age limits, deterministically.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
@@ -0,0 +1,124 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that always returns the same instant.
+type fakeClock struct{ now time.Time }
+
+func (c fakeClock) Now() time.Time { return c.now }
+
+func TestVerifyAgeBoundaries(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	body := []byte(testBody)
+	v := mustVerifier(t, testKey, WithClock(fakeClock{now: now}))
+
+	tests := []struct {
+		name string
+		sent time.Time
+		want error
+	}{
+		{"one ms under max age", now.Add(-DefaultMaxAge + time.Millisecond), nil},
+		{"exactly max age", now.Add(-DefaultMaxAge), ErrTimestampExpired},
+		{"one ms over max age", now.Add(-DefaultMaxAge - time.Millisecond), ErrTimestampExpired},
+		{"exactly clock skew", now.Add(DefaultClockSkew), nil},
+		{"one ms over clock skew", now.Add(DefaultClockSkew + time.Millisecond), ErrTimestampInFuture},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Verify(body, signedHeaders(testKey, body, tt.sent))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithClockRejectsNil(t *testing.T) {
+	if _, err := NewVerifier(testKey, WithClock(nil)); err == nil {
+		t.Fatal("NewVerifier() with nil clock succeeded, want error")
+	}
+}
+
+func TestVerifySecondTimestamps(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(testBody)
+	v := mustVerifier(t, testKey, WithClock(fakeClock{now: now}), WithTimestampUnit(UnitSeconds))
+
+	signature, timestamp := v.Sign(body, now.Add(-time.Minute))
+	if timestamp != "1699999940" {
+		t.Fatalf("Sign() timestamp = %q, want seconds", timestamp)
+	}
+
+	tests := []struct {
+		name      string
+		timestamp string
+		want      error
+	}{
+		{"one second under max age", "1699999881", nil},
+		{"exactly max age", "1699999880", ErrTimestampExpired},
+		{"within clock skew", "1700000005", nil},
+		{"beyond clock skew", "1700000006", ErrTimestampInFuture},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{}
+			headers.Set("x-timestamp", tt.timestamp)
+			headers.Set("x-signature", v.ExpectedSignature(headers, body))
+			if err := v.Verify(body, headers); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	headers := http.Header{}
+	headers.Set("x-signature", signature)
+	headers.Set("x-timestamp", timestamp)
+	if err := v.Verify(body, headers); err != nil {
+		t.Errorf("Verify() of Verifier.Sign output = %v, want nil", err)
+	}
+
+	// A millisecond timestamp read as seconds lies far in the future.
+	if err := v.Verify(body, signedHeaders(testKey, body, now)); !errors.Is(err, ErrTimestampInFuture) {
+		t.Errorf("Verify() of millisecond timestamp = %v, want %v", err, ErrTimestampInFuture)
+	}
+}
+
+func TestOnDrift(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	body := []byte(testBody)
+	provider, err := NewStaticKeyProvider("X-Project-Id", map[string][]string{"project-a": {testKey}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type drift struct {
+		tenant string
+		drift  time.Duration
+	}
+	var got []drift
+	v := mustVerifier(t, "",
+		WithClock(fakeClock{now: now}),
+		WithKeyProvider(provider),
+		WithOnDrift(func(tenant string, d time.Duration) { got = append(got, drift{tenant, d}) }),
+	)
+
+	for _, sent := range []time.Duration{-3 * time.Second, 2 * time.Second, -5 * time.Minute} {
+		headers := signedHeaders(testKey, body, now.Add(sent))
+		headers.Set("X-Project-Id", "project-a")
+		v.Verify(body, headers)
+	}
+	headers := signedHeaders("other-key", body, now)
+	headers.Set("X-Project-Id", "project-a")
+	v.Verify(body, headers)
+
+	// Rejected webhooks, whether too old or forged, are not observed.
+	want := []drift{{"project-a", 3 * time.Second}, {"project-a", -2 * time.Second}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("drift = %v, want %v", got, want)
+	}
+}
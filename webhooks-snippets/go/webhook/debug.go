@@ -0,0 +1,214 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Diagnosis explains whether a webhook request would be accepted, as
+// returned by Verifier.Diagnose.
+type Diagnosis struct {
+	// Accepted reports whether every check passed.
+	Accepted bool `json:"accepted"`
+	// FailedStep names the first check that failed: "address",
+	// "content_type", "headers", "key", "body", "signature", "event_type",
+	// "replay", "duplicate", "parse" or "validate". Error and Reason
+	// describe the failure, Reason being the value Reason returns for it.
+	FailedStep string `json:"failed_step,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+
+	// Headers reports which of the headers the webhook pipeline reads were
+	// sent, by name.
+	Headers map[string]bool `json:"headers"`
+	// ReceivedSignature is the signature header as sent, and
+	// ExpectedSignature the signature v computes for the body, as returned
+	// by Verifier.ExpectedSignature.
+	ReceivedSignature string `json:"received_signature,omitempty"`
+	ExpectedSignature string `json:"expected_signature,omitempty"`
+	// Timestamp is the timestamp header as sent, and AgeSeconds how old it
+	// was when it was diagnosed; it is negative for timestamps in the
+	// future. AgeSeconds is omitted if the timestamp is not a number.
+	Timestamp  string   `json:"timestamp,omitempty"`
+	AgeSeconds *float64 `json:"age_seconds,omitempty"`
+	// Events holds the types of the events in the body, once parsed.
+	Events []EventType `json:"events,omitempty"`
+}
+
+// Diagnose runs the checks Verifier.ReadBatch would run on r and reports
+// the outcome of each, without side effects: the delivery is not recorded
+// by the replay or duplicate stores, the rate limit, metrics, logs, event
+// and rejection sinks are left alone, and nothing is dispatched. r's body
+// is consumed.
+//
+// The diagnosis includes the signature v expects, once the address and
+// content type checks pass, which lets anyone who sees it sign arbitrary
+// bodies for the given timestamp. It is meant for onboarding and
+// debugging, and must not be exposed to untrusted clients.
+func (v *Verifier) Diagnose(r *http.Request) *Diagnosis {
+	d := &Diagnosis{
+		Headers:           make(map[string]bool),
+		ReceivedSignature: r.Header.Get(v.signatureHeader),
+		Timestamp:         r.Header.Get(v.timestampHeader),
+	}
+	for _, name := range []string{v.signatureHeader, v.timestampHeader, versionHeader, deliveryIDHeader, "Content-Type", "Content-Encoding"} {
+		d.Headers[http.CanonicalHeaderKey(name)] = r.Header.Get(name) != ""
+	}
+	if n, err := strconv.ParseInt(d.Timestamp, 10, 64); err == nil {
+		age := v.clock.Now().Sub(v.timestampUnit.time(n)).Seconds()
+		d.AgeSeconds = &age
+	}
+
+	// The expected signature is only given to requests that would get as
+	// far as the signature check.
+	if err := v.checkRequest(r); err != nil {
+		return d.fail(err)
+	}
+	signed, body, bodyErr := v.diagnosisBody(r)
+	if bodyErr == nil {
+		d.ExpectedSignature = v.ExpectedSignature(r.Header, signed)
+	}
+	if err := v.diagnoseSignature(r, signed, body, bodyErr, d); err != nil {
+		return d.fail(err)
+	}
+	d.Accepted = true
+	return d
+}
+
+// checkRequest runs the checks ReadRequest makes before reading the body.
+// The rate limit is skipped, since checking it takes a token.
+func (v *Verifier) checkRequest(r *http.Request) *diagnosisError {
+	if v.allowedNets != nil && !v.addressAllowed(v.clientIP(r)) {
+		return &diagnosisError{"address", ErrForbiddenAddress}
+	}
+	if v.contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || !strings.EqualFold(mediaType, v.contentType) {
+			return &diagnosisError{"content_type", ErrUnsupportedMediaType}
+		}
+	}
+	return nil
+}
+
+// diagnosisBody reads r's body, returning the bytes the signature covers
+// and the decompressed body, as configured with WithGzipSigning.
+func (v *Verifier) diagnosisBody(r *http.Request) (signed, body []byte, err *diagnosisError) {
+	encoding, encErr := contentEncoding(r.Header.Get("Content-Encoding"))
+	if encErr != nil {
+		return nil, nil, &diagnosisError{"body", encErr}
+	}
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	raw, readErr := readBody(r.Body, v.maxBodySize)
+	if readErr != nil {
+		return nil, nil, &diagnosisError{"body", readErr}
+	}
+	if encoding == "" {
+		return raw, raw, nil
+	}
+	decompressed, decErr := decompress(raw, v.maxBodySize)
+	if decErr != nil {
+		return nil, nil, &diagnosisError{"body", decErr}
+	}
+	if v.gzipSigning == SignedCompressed {
+		return raw, decompressed, nil
+	}
+	return decompressed, decompressed, nil
+}
+
+// diagnoseSignature runs the checks made from the signature headers on,
+// in the order ReadBatch makes them, recording the parsed events in d.
+// bodyErr is the error diagnosisBody returned, if any.
+func (v *Verifier) diagnoseSignature(r *http.Request, signed, body []byte, bodyErr *diagnosisError, d *Diagnosis) *diagnosisError {
+	sig, err := v.parseHeaders(r.Header)
+	if err != nil {
+		return &diagnosisError{"headers", err}
+	}
+	keys, err := v.requestKeys(r.Header)
+	if err != nil {
+		return &diagnosisError{"key", err}
+	}
+	if bodyErr != nil {
+		return bodyErr
+	}
+	if len(signed) == 0 {
+		return &diagnosisError{"body", ErrEmptyBody}
+	}
+	if signed, err = v.canonicalBody(signed); err != nil {
+		return &diagnosisError{"body", err}
+	}
+	check := v.newCheck(keys, signedPrefix(sig.timestamp, sig.version))
+	check.Write(signed)
+	matched, mac := check.match(signed, sig.decoded)
+	if matched == -1 {
+		return &diagnosisError{"signature", ErrSignatureMismatch}
+	}
+	if v.allowedTypes != nil {
+		if eventType, ok := peekEventType(signed); ok && !v.allowedTypes[eventType] {
+			return &diagnosisError{"event_type", ErrEventIgnored}
+		}
+	}
+	// Seen only reads the stores; the delivery is not remembered.
+	if v.replay != nil && v.replay.Seen(r.Context(), deliveryID(sig.timestamp, mac)) {
+		return &diagnosisError{"replay", ErrReplayDetected}
+	}
+	if v.dedupe != nil && v.dedupe.Seen(r.Context(), payloadID(signed)) {
+		return &diagnosisError{"duplicate", ErrDuplicateEvent}
+	}
+
+	events, raws, err := parseBatch(body)
+	if err != nil {
+		return &diagnosisError{"parse", err}
+	}
+	if v.allowedTypes != nil && !slices.ContainsFunc(events, func(e *WebhookEvent) bool { return v.allowedTypes[e.Type] }) {
+		return &diagnosisError{"event_type", ErrEventIgnored}
+	}
+	for i, event := range events {
+		d.Events = append(d.Events, event.Type)
+		if err := v.checkEvent(event, raws[i], r.Header); err != nil {
+			if len(events) > 1 {
+				err = fmt.Errorf("event %d: %w", i, err)
+			}
+			return &diagnosisError{"validate", err}
+		}
+	}
+	return nil
+}
+
+type diagnosisError struct {
+	step string
+	err  error
+}
+
+func (d *Diagnosis) fail(err *diagnosisError) *Diagnosis {
+	d.FailedStep = err.step
+	d.Error = err.err.Error()
+	d.Reason = Reason(err.err)
+	return d
+}
+
+// DebugHandler returns a net/http handler that answers every request with
+// the JSON encoding of v.Diagnose, with status 200 whether the webhook
+// would be accepted or not. It is meant for onboarding new integrations:
+// since the diagnosis reveals the expected signature, the handler must be
+// mounted on a separate, authenticated route that is disabled in
+// production.
+func DebugHandler(v *Verifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		enc.Encode(v.Diagnose(r))
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(buf.Bytes())
+	}
+}
@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDiagnose(t *testing.T) {
+	body := []byte(testBody)
+	now := time.Now()
+	badTimestamp := signedHeaders(testKey, body, now)
+	badTimestamp.Set("X-Timestamp", "soon")
+
+	tests := []struct {
+		name    string
+		headers http.Header
+		step    string
+	}{
+		{"accepted", signedHeaders(testKey, body, now), ""},
+		{"wrong key", signedHeaders("wrong-key", body, now), "signature"},
+		{"expired", signedHeaders(testKey, body, now.Add(-time.Hour)), "headers"},
+		{"invalid timestamp", badTimestamp, "headers"},
+		{"unsigned", http.Header{}, "headers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := mustVerifier(t, testKey)
+			d := v.Diagnose(newRequest(body, tt.headers))
+
+			if d.Accepted != (tt.step == "") || d.FailedStep != tt.step {
+				t.Fatalf("diagnosis = %+v, want failed step %q", d, tt.step)
+			}
+			if want := v.ExpectedSignature(tt.headers, body); d.ExpectedSignature != want {
+				t.Errorf("expected signature = %q, want %q", d.ExpectedSignature, want)
+			}
+			if d.Headers["X-Signature"] != (tt.headers.Get("X-Signature") != "") {
+				t.Errorf("headers = %v", d.Headers)
+			}
+		})
+	}
+}
+
+func TestDiagnoseHasNoSideEffects(t *testing.T) {
+	body := []byte(testBody)
+	headers := signedHeaders(testKey, body, time.Now())
+	store := NewMemoryReplayStore(time.Minute)
+	defer store.Close()
+	sink := &MemorySink{}
+	v := mustVerifier(t, testKey, WithReplayCache(store), WithEventSink(sink))
+
+	rec := serve(DebugHandler(v), newRequest(body, headers))
+	var d Diagnosis
+	if err := json.Unmarshal(rec.Body.Bytes(), &d); err != nil {
+		t.Fatalf("response %q is not JSON: %v", rec.Body, err)
+	}
+	if !d.Accepted || len(d.Events) != 1 || d.Events[0] != EventMeetingStarted {
+		t.Fatalf("diagnosis = %+v, want accepted meetingStarted", d)
+	}
+	if len(sink.Events()) != 0 {
+		t.Error("Diagnose stored the event")
+	}
+	// The delivery was not remembered, so it is not a replay.
+	if _, _, err := v.ReadRequest(newRequest(body, headers)); err != nil {
+		t.Errorf("ReadRequest() after Diagnose = %v", err)
+	}
+}
+
+func TestDiagnoseCheckOrder(t *testing.T) {
+	body := []byte(testBody)
+	dedupe := NewMemoryReplayStore(time.Minute)
+	defer dedupe.Close()
+	v := mustVerifier(t, testKey, WithAllowedEventTypes(EventRecordingEnded), WithDedupeByPayload(dedupe, time.Hour))
+	dedupe.Remember(t.Context(), payloadID(body), time.Hour)
+
+	// A duplicate of an ignored type is acknowledged as ignored.
+	headers := signedHeaders(testKey, body, time.Now())
+	if _, _, err := v.ReadRequest(newRequest(body, headers)); !errors.Is(err, ErrEventIgnored) {
+		t.Fatalf("ReadRequest() = %v, want %v", err, ErrEventIgnored)
+	}
+	if d := v.Diagnose(newRequest(body, headers)); d.FailedStep != "event_type" {
+		t.Errorf("failed step = %q, want %q", d.FailedStep, "event_type")
+	}
+}
+
+func TestDiagnoseForbiddenAddress(t *testing.T) {
+	body := []byte(testBody)
+	v := mustVerifier(t, testKey, WithAllowedCIDRs([]string{"10.0.0.0/8"}))
+
+	d := v.Diagnose(newRequest(body, signedHeaders("wrong-key", body, time.Now())))
+	if d.FailedStep != "address" || d.ExpectedSignature != "" {
+		t.Errorf("diagnosis = %+v, want the address step and no expected signature", d)
+	}
+}
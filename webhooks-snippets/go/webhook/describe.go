@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// Settings is the effective configuration of a Verifier, as returned by
+// Verifier.Describe: the defaults with the options applied. It never holds
+// the keys themselves, only how many there are.
+type Settings struct {
+	// SignatureHeader and TimestampHeader name the headers the signature
+	// and its timestamp are read from.
+	SignatureHeader string
+	TimestampHeader string
+	Scheme          SignatureScheme
+	Hash            HashAlgorithm
+	Encoding        Encoding
+	TimestampUnit   TimestampUnit
+	MaxAge          time.Duration
+	ClockSkew       time.Duration
+
+	// Keys is the number of keys accepted, counting the primary key. It is
+	// zero with a KeyProvider, which chooses the keys for each webhook.
+	Keys        int
+	KeyProvider bool
+	KeyDeriver  bool
+
+	// ReplayCache, Dedupe and ResponseCache report whether a store is set
+	// with WithReplayCache, WithDedupeByPayload and WithResponseCache.
+	ReplayCache   bool
+	Dedupe        bool
+	DedupeTTL     time.Duration
+	ResponseCache bool
+
+	MaxBodySize int64
+	// ContentType is the media type required with WithRequireContentType,
+	// or empty if any is accepted.
+	ContentType       string
+	GzipSigning       GzipSigning
+	BodyCanonicalizer bool
+	// TimestampTolerance is only meaningful if TimestampMatch is set.
+	TimestampMatch     bool
+	TimestampTolerance time.Duration
+
+	// SupportedVersions, AllowedEventTypes and AllowedCIDRs are nil if
+	// every version, event type or address is accepted. AllowedEventTypes
+	// is sorted.
+	SupportedVersions []string
+	AllowedEventTypes []EventType
+	AllowedCIDRs      []netip.Prefix
+	TrustedProxies    int
+	// RateLimit and PerIPRateLimit are in requests per second, zero if
+	// there is no such limit.
+	RateLimit      float64
+	RateBurst      int
+	PerIPRateLimit float64
+	PerIPBurst     int
+
+	HandlerTimeout time.Duration
+	SuccessStatus  int
+	// Validators is the number of validators added with
+	// WithEventValidator, and Sink reports whether WithEventSink is set.
+	Validators  int
+	Sink        bool
+	SinkFailure SinkFailureMode
+}
+
+// Describe returns v's effective configuration, for example to check at
+// startup or in tests that v is configured as expected. It is safe to log:
+// the keys are not included.
+func (v *Verifier) Describe() Settings {
+	s := Settings{
+		SignatureHeader:    v.signatureHeader,
+		TimestampHeader:    v.timestampHeader,
+		Scheme:             v.scheme,
+		Hash:               v.hash,
+		Encoding:           v.encoding,
+		TimestampUnit:      v.timestampUnit,
+		MaxAge:             v.maxAge,
+		ClockSkew:          v.clockSkew,
+		KeyProvider:        v.keyProvider != nil,
+		KeyDeriver:         v.deriveKey != nil,
+		ReplayCache:        v.replay != nil,
+		Dedupe:             v.dedupe != nil,
+		DedupeTTL:          v.dedupeTTL,
+		ResponseCache:      v.responses != nil,
+		MaxBodySize:        v.maxBodySize,
+		ContentType:        v.contentType,
+		GzipSigning:        v.gzipSigning,
+		BodyCanonicalizer:  v.canonicalize != nil,
+		TimestampMatch:     v.matchTimestamp,
+		TimestampTolerance: v.timestampSlack,
+		SupportedVersions:  slices.Clone(v.versions),
+		AllowedCIDRs:       slices.Clone(v.allowedNets),
+		TrustedProxies:     v.trustedProxies,
+		HandlerTimeout:     v.handlerTimeout,
+		SuccessStatus:      v.successStatus,
+		Validators:         len(v.validators),
+		Sink:               v.sink != nil,
+		SinkFailure:        v.sinkFailure,
+	}
+	if keys := v.keys.Load(); keys != nil {
+		s.Keys = len(keys.keys)
+	}
+	for eventType := range v.allowedTypes {
+		s.AllowedEventTypes = append(s.AllowedEventTypes, eventType)
+	}
+	slices.Sort(s.AllowedEventTypes)
+	if v.limiter != nil {
+		if v.limiter.global != nil {
+			s.RateLimit = float64(v.limiter.global.Limit())
+			s.RateBurst = v.limiter.global.Burst()
+		}
+		if v.limiter.perIP {
+			s.PerIPRateLimit = float64(v.limiter.ipRate)
+			s.PerIPBurst = v.limiter.ipBurst
+		}
+	}
+	return s
+}
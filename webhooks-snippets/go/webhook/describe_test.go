@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescribe(t *testing.T) {
+	got := mustVerifier(t, testKey).Describe()
+	if got.SignatureHeader != DefaultSignatureHeader || got.TimestampHeader != DefaultTimestampHeader ||
+		got.MaxAge != DefaultMaxAge || got.TimestampUnit != UnitMilliseconds || got.Hash != HashSHA256 ||
+		got.Keys != 1 || got.ReplayCache || got.SuccessStatus != http.StatusOK {
+		t.Errorf("Describe() of default verifier = %+v", got)
+	}
+
+	replay := NewMemoryReplayStore(time.Minute)
+	defer replay.Close()
+	v := mustVerifier(t, testKey,
+		WithAdditionalKeys("old-key"),
+		WithSignatureHeader("x-meet-signature"),
+		WithMaxAge(time.Minute),
+		WithTimestampUnit(UnitSeconds),
+		WithHashAlgorithm(HashSHA512),
+		WithReplayCache(replay),
+		WithAllowedEventTypes(EventRoomClosed, EventMeetingStarted),
+		WithPerIPRateLimit(10, 20),
+	)
+	got = v.Describe()
+	switch {
+	case got.SignatureHeader != "x-meet-signature", got.MaxAge != time.Minute,
+		got.TimestampUnit != UnitSeconds, got.Hash != HashSHA512:
+		t.Errorf("Describe() = %+v, want the configured headers, max age, unit and hash", got)
+	case got.Keys != 2 || !got.ReplayCache:
+		t.Errorf("Describe() = %+v, want 2 keys and a replay cache", got)
+	case !slices.Equal(got.AllowedEventTypes, []EventType{EventMeetingStarted, EventRoomClosed}):
+		t.Errorf("AllowedEventTypes = %v, want sorted allowed types", got.AllowedEventTypes)
+	case got.PerIPRateLimit != 10 || got.PerIPBurst != 20 || got.RateLimit != 0:
+		t.Errorf("Describe() = %+v, want a per-IP limit of 10/s with burst 20", got)
+	}
+	if s := fmt.Sprintf("%+v", got); strings.Contains(s, testKey) || strings.Contains(s, "old-key") {
+		t.Errorf("Describe() = %s, includes a key", s)
+	}
+}
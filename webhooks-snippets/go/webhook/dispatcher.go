@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// HandlerFunc processes a parsed webhook event. Handlers should stop and
+// return when ctx is done.
+type HandlerFunc func(ctx context.Context, event *WebhookEvent) error
+
+// Dispatcher routes parsed webhook events to the handler registered for
+// their type. The zero value is ready to use and safe for concurrent use.
+type Dispatcher struct {
+	mu       sync.RWMutex
+	handlers map[EventType]HandlerFunc
+	fallback HandlerFunc
+	unknown  UnknownHandlerFunc
+	logger   *slog.Logger
+}
+
+// UnknownHandlerFunc processes an event whose type this package does not
+// know, such as one added by a newer OpenVidu Meet release. raw is the
+// event's undecoded payload.
+type UnknownHandlerFunc func(raw []byte, eventType string) error
+
+// On registers handler for events of type eventType, replacing any handler
+// previously registered for it.
+func (d *Dispatcher) On(eventType EventType, handler HandlerFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.handlers == nil {
+		d.handlers = make(map[EventType]HandlerFunc)
+	}
+	d.handlers[eventType] = handler
+}
+
+// OnDefault registers handler for events whose type has no handler
+// registered with On.
+func (d *Dispatcher) OnDefault(handler HandlerFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.fallback = handler
+}
+
+// OnUnknown registers handler for events with no handler registered with
+// On whose type is not known to this package (see EventType.Known). It
+// takes precedence over the OnDefault handler for those events.
+func (d *Dispatcher) OnUnknown(handler UnknownHandlerFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.unknown = handler
+}
+
+// SetLogger sets the logger unknown events are reported to when there is no
+// handler for them. The default is slog.Default().
+func (d *Dispatcher) SetLogger(logger *slog.Logger) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if logger != nil {
+		logger = withRequestIDs(logger)
+	}
+	d.logger = logger
+}
+
+// Dispatch calls the handler registered for event's type and returns its
+// error. Events with no handler of their own go to the OnUnknown handler if
+// their type is unknown, and to the OnDefault handler otherwise. Unknown
+// events no handler takes are logged at warn level; other events with no
+// matching handler are ignored.
+func (d *Dispatcher) Dispatch(ctx context.Context, event *WebhookEvent) error {
+	d.mu.RLock()
+	handler, ok := d.handlers[event.Type]
+	unknown, fallback, logger := d.unknown, d.fallback, d.logger
+	d.mu.RUnlock()
+
+	switch {
+	case ok:
+		return handler(ctx, event)
+	case !event.Type.Known() && unknown != nil:
+		return unknown(event.Data, string(event.Type))
+	case fallback != nil:
+		return fallback(ctx, event)
+	case !event.Type.Known():
+		if logger == nil {
+			logger = slog.Default()
+		}
+		logger.WarnContext(ctx, "Ignoring webhook of unknown type", "event", event.Type)
+	}
+	return nil
+}
+
+// BatchMode decides what happens to the rest of a batch when handling one
+// of its events fails.
+type BatchMode int
+
+const (
+	// BatchAbort stops at the first failing event and returns its error.
+	BatchAbort BatchMode = iota
+	// BatchContinue handles every event and returns the errors of those that
+	// failed, joined with errors.Join.
+	BatchContinue
+)
+
+func (m BatchMode) String() string {
+	switch m {
+	case BatchAbort:
+		return "abort"
+	case BatchContinue:
+		return "continue"
+	default:
+		return fmt.Sprintf("BatchMode(%d)", int(m))
+	}
+}
+
+// HandleBatch calls handler with each of events in order. Errors name the
+// index and type of the event that failed.
+func HandleBatch(ctx context.Context, events []*WebhookEvent, handler HandlerFunc, mode BatchMode) error {
+	var errs []error
+	for i, event := range events {
+		if err := handler(ctx, event); err != nil {
+			err = fmt.Errorf("event %d (%s): %w", i, event.Type, err)
+			if mode == BatchAbort {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DispatchBatch dispatches each of events in order; see HandleBatch.
+func (d *Dispatcher) DispatchBatch(ctx context.Context, events []*WebhookEvent, mode BatchMode) error {
+	return HandleBatch(ctx, events, d.Dispatch, mode)
+}
@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// Encoding is the text encoding of the signature sent in the signature header.
+type Encoding int
+
+const (
+	// EncodingHex is lowercase or uppercase hexadecimal. OpenVidu Meet sends
+	// hex signatures, so this is the default.
+	EncodingHex Encoding = iota
+	// EncodingBase64 is standard base64 with padding.
+	EncodingBase64
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case EncodingHex:
+		return "hex"
+	case EncodingBase64:
+		return "base64"
+	default:
+		return fmt.Sprintf("Encoding(%d)", int(e))
+	}
+}
+
+func (e Encoding) decode(s string) ([]byte, error) {
+	switch e {
+	case EncodingBase64:
+		return base64.StdEncoding.DecodeString(s)
+	default:
+		return hex.DecodeString(s)
+	}
+}
+
+func (e Encoding) encode(b []byte) string {
+	switch e {
+	case EncodingBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	default:
+		return hex.EncodeToString(b)
+	}
+}
@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Errors returned by Verifier.Verify, Verifier.ReadRequest and ParseEvent.
+// Callers can match them with errors.Is to tell malformed requests apart from
+// stale or forged ones.
+var (
+	ErrMissingSignature     = errors.New("webhook: missing signature header")
+	ErrMissingTimestamp     = errors.New("webhook: missing timestamp header")
+	ErrInvalidTimestamp     = errors.New("webhook: invalid timestamp header")
+	ErrTimestampExpired     = errors.New("webhook: timestamp expired")
+	ErrTimestampInFuture    = errors.New("webhook: timestamp too far in the future")
+	ErrTimestampMismatch    = errors.New("webhook: timestamp header does not match payload")
+	ErrMalformedSignature   = errors.New("webhook: malformed signature")
+	ErrUnsupportedAlgorithm = errors.New("webhook: unsupported signature algorithm")
+	ErrUnsupportedVersion   = errors.New("webhook: unsupported webhook version")
+	ErrSignatureMismatch    = errors.New("webhook: signature mismatch")
+	ErrUnknownTenant        = errors.New("webhook: unknown tenant")
+	ErrReplayDetected       = errors.New("webhook: replayed delivery")
+	ErrDuplicateEvent       = errors.New("webhook: duplicate event")
+	ErrInvalidEvent         = errors.New("webhook: invalid event")
+	ErrInvalidPayload       = errors.New("webhook: invalid payload")
+	ErrReadBody             = errors.New("webhook: failed to read request body")
+	ErrEmptyBody            = errors.New("webhook: empty request body")
+	ErrInvalidEncoding      = errors.New("webhook: malformed gzip body")
+	ErrBodyTooLarge         = errors.New("webhook: request body too large")
+	ErrUnsupportedMediaType = errors.New("webhook: unsupported content type")
+	ErrRateLimited          = errors.New("webhook: rate limit exceeded")
+	ErrForbiddenAddress     = errors.New("webhook: remote address not allowed")
+	ErrSinkFailed           = errors.New("webhook: failed to store event")
+
+	// ErrEventIgnored reports a verified webhook whose event type is not
+	// accepted by WithAllowedEventTypes. It is not a failure: StatusCode
+	// maps it to 200, and Verifier.WriteError acknowledges it as a success.
+	ErrEventIgnored = errors.New("webhook: event type ignored")
+)
+
+// Errors returned by Verifier.RunHandler, WorkerPool.Submit,
+// EventStream.Send and Forwarder.ForwardAsync.
+var (
+	ErrHandlerTimeout  = errors.New("webhook: handler timed out")
+	ErrHandlerCanceled = errors.New("webhook: handler canceled")
+	ErrHandlerPanic    = errors.New("webhook: handler panicked")
+
+	ErrQueueFull   = errors.New("webhook: processing queue full")
+	ErrQueueClosed = errors.New("webhook: processing queue closed")
+)
+
+// ErrPoolClosed is what WorkerPool.Submit returned after Close before the
+// error was shared with EventStream and Forwarder.
+//
+// Deprecated: Use ErrQueueClosed, which ErrPoolClosed is the same error as.
+var ErrPoolClosed = ErrQueueClosed
+
+// StatusCode returns the HTTP status code a webhook endpoint should answer
+// with when verifying, queueing or handling a webhook fails with err.
+//
+// Replays and duplicates get 409 rather than a 2xx on purpose: the copy
+// they repeat may still be being handled, and if that fails its delivery
+// is released, so the sender must keep retrying rather than take the
+// repeat as delivered. Retries of deliveries already handled are answered
+// with their stored 2xx response with WithResponseCache, so senders stop
+// retrying them.
+func StatusCode(err error) int {
+	switch {
+	case err == nil, errors.Is(err, ErrEventIgnored):
+		return http.StatusOK
+	case errors.Is(err, ErrMissingSignature),
+		errors.Is(err, ErrMissingTimestamp),
+		errors.Is(err, ErrInvalidTimestamp),
+		errors.Is(err, ErrMalformedSignature),
+		errors.Is(err, ErrUnsupportedAlgorithm),
+		errors.Is(err, ErrUnsupportedVersion),
+		errors.Is(err, ErrInvalidEvent),
+		errors.Is(err, ErrInvalidPayload),
+		errors.Is(err, ErrReadBody),
+		errors.Is(err, ErrEmptyBody),
+		errors.Is(err, ErrInvalidEncoding):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrTimestampExpired),
+		errors.Is(err, ErrTimestampInFuture),
+		errors.Is(err, ErrTimestampMismatch),
+		errors.Is(err, ErrSignatureMismatch),
+		errors.Is(err, ErrUnknownTenant):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrForbiddenAddress):
+		return http.StatusForbidden
+	case errors.Is(err, ErrBodyTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrSinkFailed),
+		errors.Is(err, ErrQueueFull),
+		errors.Is(err, ErrQueueClosed),
+		errors.Is(err, ErrHandlerTimeout),
+		errors.Is(err, ErrHandlerCanceled):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrReplayDetected),
+		errors.Is(err, ErrDuplicateEvent):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
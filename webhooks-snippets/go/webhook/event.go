@@ -0,0 +1,409 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// EventType identifies the kind of a webhook event.
+type EventType string
+
+// Event types emitted by OpenVidu Meet.
+const (
+	EventMeetingStarted   EventType = "meetingStarted"
+	EventMeetingEnded     EventType = "meetingEnded"
+	EventRecordingStarted EventType = "recordingStarted"
+	EventRecordingUpdated EventType = "recordingUpdated"
+	EventRecordingEnded   EventType = "recordingEnded"
+	// EventRecordingReady announces that a recording file is available for
+	// post-processing.
+	EventRecordingReady EventType = "recordingReady"
+	// EventRoomCreated and EventRoomClosed track the lifecycle of a room.
+	EventRoomCreated EventType = "roomCreated"
+	EventRoomClosed  EventType = "roomClosed"
+	// EventParticipantJoined and EventParticipantLeft track presence in a
+	// room.
+	EventParticipantJoined EventType = "participantJoined"
+	EventParticipantLeft   EventType = "participantLeft"
+)
+
+// Known reports whether t is one of the event types defined by this package,
+// whose payload ParseEvent decodes.
+func (t EventType) Known() bool {
+	switch t {
+	case EventMeetingStarted, EventMeetingEnded,
+		EventRecordingStarted, EventRecordingUpdated, EventRecordingEnded, EventRecordingReady,
+		EventRoomCreated, EventRoomClosed,
+		EventParticipantJoined, EventParticipantLeft:
+		return true
+	}
+	return false
+}
+
+// WebhookEvent is a webhook event sent by OpenVidu Meet.
+//
+// Depending on Type, ParseEvent decodes Data into Room (meeting events),
+// Recording (recording events), RecordingReady, RoomLifecycle (room
+// lifecycle events) or Participant (participant events). Data always holds
+// the raw payload, so event types unknown to this package can still be
+// decoded by the caller.
+type WebhookEvent struct {
+	// Type is the kind of event.
+	Type EventType `json:"event"`
+	// CreationDate is when the event was created, in milliseconds since epoch.
+	CreationDate int64 `json:"creationDate"`
+	// Data is the raw event payload.
+	Data json.RawMessage `json:"data"`
+	// Version is the webhook format version, read from the envelope's
+	// "version" field or, by Verifier.ReadRequest, from the
+	// "x-webhook-version" header. It is empty for unversioned webhooks,
+	// which is what current OpenVidu Meet releases send.
+	Version string `json:"version,omitempty"`
+	// Sequence is the sender's sequence number for the event's room, if
+	// the envelope carries one. Current OpenVidu Meet releases do not send
+	// it, so it is usually zero; see WithOrderingCheck.
+	Sequence int64 `json:"sequence,omitempty"`
+
+	// RawTimestamp is the timestamp header the webhook was signed with, and
+	// Timestamp its value. Age is how old the webhook was when it was
+	// received. They are set by Verifier.ReadRequest.
+	RawTimestamp string        `json:"-"`
+	Timestamp    time.Time     `json:"-"`
+	Age          time.Duration `json:"-"`
+
+	// RawBody is the verified body the event was parsed from. It is set by
+	// Verifier.VerifyAndParse; ReadRequest returns the body instead.
+	RawBody []byte `json:"-"`
+
+	// RequestID is the correlation id of the request that delivered the
+	// event (see AttachRequestID). It is set by Verifier.ReadRequest.
+	RequestID string `json:"-"`
+
+	// RoomID is the room the event refers to, if any.
+	RoomID string `json:"-"`
+	// Room is set for meeting events.
+	Room *Room `json:"-"`
+	// Recording is set for recording events.
+	Recording *Recording `json:"-"`
+	// RecordingReady is set for EventRecordingReady.
+	RecordingReady *RecordingReadyPayload `json:"-"`
+	// RoomLifecycle is set for EventRoomCreated and EventRoomClosed.
+	RoomLifecycle *RoomPayload `json:"-"`
+	// Participant is set for EventParticipantJoined and EventParticipantLeft.
+	Participant *ParticipantPayload `json:"-"`
+}
+
+// CreatedAt returns CreationDate as a time.Time.
+func (e *WebhookEvent) CreatedAt() time.Time {
+	return time.UnixMilli(e.CreationDate)
+}
+
+// Room is the payload of meeting events.
+type Room struct {
+	RoomID           string `json:"roomId"`
+	RoomName         string `json:"roomName"`
+	Owner            string `json:"owner,omitempty"`
+	CreationDate     int64  `json:"creationDate"`
+	AutoDeletionDate int64  `json:"autoDeletionDate,omitempty"`
+	Status           string `json:"status,omitempty"`
+	MeetingEndAction string `json:"meetingEndAction,omitempty"`
+}
+
+// Validate checks that the room is identified.
+func (r *Room) Validate() error {
+	if r.RoomID == "" {
+		return missingField("roomId")
+	}
+	return nil
+}
+
+// Recording is the payload of recording events.
+type Recording struct {
+	RecordingID string `json:"recordingId"`
+	RoomID      string `json:"roomId"`
+	RoomName    string `json:"roomName"`
+	Status      string `json:"status"`
+	Layout      string `json:"layout,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+	// StartDate and EndDate are in milliseconds since epoch.
+	StartDate int64 `json:"startDate,omitempty"`
+	EndDate   int64 `json:"endDate,omitempty"`
+	// Duration is in seconds.
+	Duration float64 `json:"duration,omitempty"`
+	// Size is in bytes.
+	Size      int64  `json:"size,omitempty"`
+	ErrorCode int    `json:"errorCode,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Details   string `json:"details,omitempty"`
+}
+
+// Validate checks that the recording and its room are identified.
+func (r *Recording) Validate() error {
+	switch {
+	case r.RecordingID == "":
+		return missingField("recordingId")
+	case r.RoomID == "":
+		return missingField("roomId")
+	}
+	return nil
+}
+
+// RecordingReadyPayload is the payload of EventRecordingReady.
+type RecordingReadyPayload struct {
+	RecordingID string `json:"recordingId"`
+	RoomID      string `json:"roomId"`
+	// Duration is in seconds.
+	Duration float64 `json:"duration"`
+	// Size is in bytes.
+	Size int64 `json:"size"`
+	// Location is the URL or storage path of the recording file.
+	Location string `json:"location"`
+}
+
+// Validate checks that the fields needed to act on the recording are set.
+func (p *RecordingReadyPayload) Validate() error {
+	switch {
+	case p.RecordingID == "":
+		return missingField("recordingId")
+	case p.RoomID == "":
+		return missingField("roomId")
+	case p.Location == "":
+		return missingField("location")
+	case p.Duration < 0:
+		return &PayloadError{Field: "duration", Problem: "must not be negative"}
+	case p.Size < 0:
+		return &PayloadError{Field: "size", Problem: "must not be negative"}
+	}
+	return nil
+}
+
+// RoomPayload is the payload of room lifecycle events.
+type RoomPayload struct {
+	RoomID   string `json:"roomId"`
+	RoomName string `json:"roomName"`
+	// CreationDate is in milliseconds since epoch.
+	CreationDate int64 `json:"creationDate"`
+	// ClosureReason explains why the room was closed. It is only set for
+	// EventRoomClosed.
+	ClosureReason string `json:"closureReason,omitempty"`
+	// Stats summarizes the room's activity. Senders may omit it, so it can
+	// be nil even for EventRoomClosed.
+	Stats *RoomStats `json:"stats,omitempty"`
+}
+
+// RoomStats summarizes the activity of a closed room.
+type RoomStats struct {
+	Meetings     int `json:"meetings,omitempty"`
+	Participants int `json:"participants,omitempty"`
+	Recordings   int `json:"recordings,omitempty"`
+}
+
+// Validate checks that the room is identified.
+func (p *RoomPayload) Validate() error {
+	if p.RoomID == "" {
+		return missingField("roomId")
+	}
+	return nil
+}
+
+// Reasons a participant left a room.
+const (
+	// LeaveReasonLeft is a graceful exit.
+	LeaveReasonLeft = "left"
+	// LeaveReasonDisconnected is an abrupt disconnection, such as a network
+	// drop.
+	LeaveReasonDisconnected = "disconnected"
+)
+
+// ParticipantPayload is the payload of participant events.
+type ParticipantPayload struct {
+	ParticipantID string `json:"participantId"`
+	Name          string `json:"participantName"`
+	Role          string `json:"role,omitempty"`
+	RoomID        string `json:"roomId"`
+	// Timestamp is when the participant joined or left, in milliseconds
+	// since epoch.
+	Timestamp int64 `json:"timestamp"`
+	// Reason is one of the LeaveReason* values. It is only set for
+	// EventParticipantLeft.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Validate checks that the participant and room are identified.
+func (p *ParticipantPayload) Validate() error {
+	switch {
+	case p.ParticipantID == "":
+		return missingField("participantId")
+	case p.RoomID == "":
+		return missingField("roomId")
+	}
+	return nil
+}
+
+// ParseEvent decodes a webhook body. It should only be called on bodies that
+// have passed Verifier.Verify. Errors wrap ErrInvalidEvent; those caused by
+// a missing field or a field of the wrong type are *PayloadError values,
+// which also match ErrInvalidPayload.
+func ParseEvent(body []byte) (*WebhookEvent, error) {
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, jsonError(&event, "", err)
+	}
+	if event.Type == "" {
+		return nil, &PayloadError{Field: "event", Problem: "missing"}
+	}
+
+	var err error
+	switch event.Type {
+	case EventMeetingStarted, EventMeetingEnded:
+		event.Room = &Room{}
+		err = decodePayload(&event, event.Room)
+		event.RoomID = event.Room.RoomID
+	case EventRecordingStarted, EventRecordingUpdated, EventRecordingEnded:
+		event.Recording = &Recording{}
+		err = decodePayload(&event, event.Recording)
+		event.RoomID = event.Recording.RoomID
+	case EventRecordingReady:
+		event.RecordingReady = &RecordingReadyPayload{}
+		err = decodePayload(&event, event.RecordingReady)
+		event.RoomID = event.RecordingReady.RoomID
+	case EventRoomCreated, EventRoomClosed:
+		event.RoomLifecycle = &RoomPayload{}
+		err = decodePayload(&event, event.RoomLifecycle)
+		event.RoomID = event.RoomLifecycle.RoomID
+	case EventParticipantJoined, EventParticipantLeft:
+		event.Participant = &ParticipantPayload{}
+		err = decodePayload(&event, event.Participant)
+		event.RoomID = event.Participant.RoomID
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// ParseEvents is like ParseEvent but also accepts a JSON array of events,
+// as sent for batched deliveries. A single event is returned as a batch of
+// one. Errors name the index of the offending event.
+func ParseEvents(body []byte) ([]*WebhookEvent, error) {
+	events, _, err := parseBatch(body)
+	return events, err
+}
+
+// parseBatch is ParseEvents, also returning the raw JSON of each event.
+func parseBatch(body []byte) ([]*WebhookEvent, []json.RawMessage, error) {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		event, err := ParseEvent(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []*WebhookEvent{event}, []json.RawMessage{body}, nil
+	}
+
+	var raws []json.RawMessage
+	if err := json.Unmarshal(body, &raws); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	if len(raws) == 0 {
+		return nil, nil, fmt.Errorf("%w: empty batch", ErrInvalidEvent)
+	}
+	events := make([]*WebhookEvent, len(raws))
+	for i, raw := range raws {
+		event, err := ParseEvent(raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("event %d: %w", i, err)
+		}
+		events[i] = event
+	}
+	return events, raws, nil
+}
+
+// decodePayload decodes event's data into dst and validates it if dst has a
+// Validate method.
+func decodePayload(event *WebhookEvent, dst any) error {
+	if len(event.Data) == 0 || string(event.Data) == "null" {
+		return &PayloadError{Event: event.Type, Field: "data", Problem: "missing"}
+	}
+	if err := json.Unmarshal(event.Data, dst); err != nil {
+		return jsonError(event, "data.", err)
+	}
+	if v, ok := dst.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			var pe *PayloadError
+			if errors.As(err, &pe) {
+				pe.Event = event.Type
+				pe.Field = "data." + pe.Field
+				return pe
+			}
+			return fmt.Errorf("%w: invalid %s payload: %v", ErrInvalidPayload, event.Type, err)
+		}
+	}
+	return nil
+}
+
+// PayloadError reports a webhook payload field that is missing or has the
+// wrong type. It matches both ErrInvalidPayload and ErrInvalidEvent.
+type PayloadError struct {
+	// Event is the event type, if it could be read.
+	Event EventType
+	// Field is the path of the offending field, such as "data.roomId".
+	Field string
+	// Problem describes what is wrong with the field, such as "missing".
+	Problem string
+}
+
+func (e *PayloadError) Error() string {
+	if e.Event == "" {
+		return fmt.Sprintf("webhook: invalid payload: %s: %s", e.Field, e.Problem)
+	}
+	return fmt.Sprintf("webhook: invalid %s payload: %s: %s", e.Event, e.Field, e.Problem)
+}
+
+func (e *PayloadError) Unwrap() []error {
+	return []error{ErrInvalidPayload, ErrInvalidEvent}
+}
+
+func missingField(name string) *PayloadError {
+	return &PayloadError{Field: name, Problem: "missing"}
+}
+
+// jsonError turns a decoding error into a *PayloadError when it is caused
+// by a field of the wrong type; other errors, such as malformed JSON, only
+// wrap ErrInvalidEvent.
+func jsonError(event *WebhookEvent, prefix string, err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return &PayloadError{
+			Event:   event.Type,
+			Field:   prefix + typeErr.Field,
+			Problem: fmt.Sprintf("expected %s, got %s", jsonKind(typeErr.Type), typeErr.Value),
+		}
+	}
+	if event.Type == "" {
+		return fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	return fmt.Errorf("%w: invalid %s payload: %v", ErrInvalidEvent, event.Type, err)
+}
+
+// jsonKind names the JSON type that decodes into t.
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,189 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseEvent(t *testing.T) {
+	event, err := ParseEvent([]byte(`{"event":"recordingEnded","creationDate":1700000000000,` +
+		`"data":{"recordingId":"rec-1","roomId":"room-1","status":"complete","duration":12.5,"size":2048}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != EventRecordingEnded || event.RoomID != "room-1" {
+		t.Errorf("event = %+v, want recordingEnded in room-1", event)
+	}
+	if r := event.Recording; r == nil || r.RecordingID != "rec-1" || r.Size != 2048 || r.Duration != 12.5 {
+		t.Errorf("recording = %+v", r)
+	}
+}
+
+func TestParseEventErrors(t *testing.T) {
+	for _, body := range []string{
+		``,
+		`{"creationDate":1700000000000}`,
+		`{"event":"meetingStarted","data":"not an object"}`,
+	} {
+		if _, err := ParseEvent([]byte(body)); !errors.Is(err, ErrInvalidEvent) {
+			t.Errorf("ParseEvent(%q) = %v, want %v", body, err, ErrInvalidEvent)
+		}
+	}
+}
+
+func TestParseEventRecordingReady(t *testing.T) {
+	event, err := ParseEvent([]byte(`{"event":"recordingReady","creationDate":1700000000000,` +
+		`"data":{"recordingId":"rec-1","roomId":"room-1","duration":60,"size":1048576,"location":"s3://recordings/rec-1.mp4"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ready := event.RecordingReady
+	if ready == nil || ready.Location != "s3://recordings/rec-1.mp4" || ready.Size != 1048576 || event.RoomID != "room-1" {
+		t.Errorf("payload = %+v", ready)
+	}
+
+	_, err = ParseEvent([]byte(`{"event":"recordingReady","data":{"recordingId":"rec-1","roomId":"room-1"}}`))
+	if !errors.Is(err, ErrInvalidEvent) {
+		t.Errorf("ParseEvent() without location = %v, want %v", err, ErrInvalidEvent)
+	}
+}
+
+func TestParseEventRoomLifecycle(t *testing.T) {
+	event, err := ParseEvent([]byte(`{"event":"roomClosed","creationDate":1700000000000,` +
+		`"data":{"roomId":"room-1","roomName":"Room 1","creationDate":1690000000000,"closureReason":"expired"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	room := event.RoomLifecycle
+	if room == nil || room.RoomID != "room-1" || room.ClosureReason != "expired" || room.Stats != nil {
+		t.Errorf("payload = %+v", room)
+	}
+
+	var d Dispatcher
+	var routed EventType
+	d.On(EventRoomClosed, func(ctx context.Context, event *WebhookEvent) error {
+		routed = event.Type
+		return nil
+	})
+	if err := d.Dispatch(context.Background(), event); err != nil || routed != EventRoomClosed {
+		t.Errorf("Dispatch() = %v, routed %q", err, routed)
+	}
+}
+
+func TestParseEventParticipantLeft(t *testing.T) {
+	event, err := ParseEvent([]byte(`{"event":"participantLeft","creationDate":1700000000000,` +
+		`"data":{"participantId":"p-1","participantName":"Alice","role":"speaker","roomId":"room-1",` +
+		`"timestamp":1700000000000,"reason":"disconnected"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := event.Participant
+	if p == nil || p.ParticipantID != "p-1" || p.Reason != LeaveReasonDisconnected || event.RoomID != "room-1" {
+		t.Errorf("payload = %+v", p)
+	}
+}
+
+func TestParseEventPayloadErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantField string
+	}{
+		{"missing event type", `{"data":{}}`, "event"},
+		{"missing data", `{"event":"meetingStarted"}`, "data"},
+		{"missing room id", `{"event":"meetingEnded","data":{"roomName":"Room 1"}}`, "data.roomId"},
+		{"missing recording id", `{"event":"recordingStarted","data":{"roomId":"room-1"}}`, "data.recordingId"},
+		{"wrong type", `{"event":"recordingEnded","data":{"recordingId":"rec-1","roomId":"room-1","size":"big"}}`, "data.size"},
+		{"wrong envelope type", `{"event":"meetingStarted","creationDate":"today","data":{"roomId":"room-1"}}`, "creationDate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseEvent([]byte(tt.body))
+
+			var pe *PayloadError
+			if !errors.As(err, &pe) {
+				t.Fatalf("ParseEvent() = %v, want a *PayloadError", err)
+			}
+			if pe.Field != tt.wantField {
+				t.Errorf("Field = %q, want %q", pe.Field, tt.wantField)
+			}
+			if !errors.Is(err, ErrInvalidPayload) || !errors.Is(err, ErrInvalidEvent) {
+				t.Errorf("ParseEvent() = %v, want it to match ErrInvalidPayload and ErrInvalidEvent", err)
+			}
+		})
+	}
+}
+
+func TestParseEvents(t *testing.T) {
+	events, err := ParseEvents([]byte(testBody))
+	if err != nil || len(events) != 1 || events[0].RoomID != "room-1" {
+		t.Fatalf("ParseEvents(single) = %v, %v", events, err)
+	}
+
+	events, err = ParseEvents([]byte(` [{"event":"meetingStarted","data":{"roomId":"room-1"}},` +
+		`{"event":"meetingEnded","data":{"roomId":"room-2"}}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[1].Type != EventMeetingEnded || events[1].RoomID != "room-2" {
+		t.Errorf("ParseEvents(batch) = %v", events)
+	}
+
+	for _, body := range []string{`[]`, `[{"event":"meetingStarted","data":{}}]`} {
+		if _, err := ParseEvents([]byte(body)); !errors.Is(err, ErrInvalidEvent) {
+			t.Errorf("ParseEvents(%q) = %v, want %v", body, err, ErrInvalidEvent)
+		}
+	}
+}
+
+func TestDispatcherOnUnknown(t *testing.T) {
+	event, err := ParseEvent([]byte(`{"event":"breakoutRoomOpened","data":{"roomId":"room-1","breakout":2}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	var d Dispatcher
+	d.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	if err := d.Dispatch(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logs.String(), "level=WARN") || !strings.Contains(logs.String(), "breakoutRoomOpened") {
+		t.Errorf("unhandled unknown event logged %q, want a warning naming its type", logs.String())
+	}
+
+	var gotRaw, gotType string
+	d.OnDefault(func(ctx context.Context, event *WebhookEvent) error {
+		t.Errorf("default handler called for %s", event.Type)
+		return nil
+	})
+	d.OnUnknown(func(raw []byte, eventType string) error {
+		gotRaw, gotType = string(raw), eventType
+		return nil
+	})
+	if err := d.Dispatch(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if gotType != "breakoutRoomOpened" || gotRaw != `{"roomId":"room-1","breakout":2}` {
+		t.Errorf("OnUnknown got (%q, %q)", gotRaw, gotType)
+	}
+
+	// Known types still go to the default handler.
+	known, err := ParseEvent([]byte(testBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var defaulted bool
+	d.OnDefault(func(ctx context.Context, event *WebhookEvent) error {
+		defaulted = true
+		return nil
+	})
+	d.Dispatch(context.Background(), known)
+	if !defaulted {
+		t.Error("default handler not called for a known event type")
+	}
+}
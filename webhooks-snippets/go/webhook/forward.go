@@ -0,0 +1,434 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultForwardTimeout bounds each attempt to deliver a webhook to an
+	// Endpoint that sets no Timeout of its own.
+	DefaultForwardTimeout = 5 * time.Second
+	// MaxForwardRetryDelay bounds the wait between two delivery attempts,
+	// whether it comes from the backoff or from a Retry-After header.
+	MaxForwardRetryDelay = time.Minute
+)
+
+// Endpoint is a downstream URL a Forwarder relays webhooks to.
+type Endpoint struct {
+	URL string
+	// Key, if set, is the API key the payload is re-signed with (see
+	// SignVersion), so the downstream can verify it with a key of its own;
+	// the signature is sent in the default x-signature and x-timestamp
+	// headers, and covers the canonical form of the payload with
+	// WithForwardHeadersFrom and a Verifier using WithBodyCanonicalizer.
+	// Otherwise the original signature and timestamp headers are passed
+	// through, under the names set with WithForwardHeadersFrom.
+	Key string
+	// Timeout bounds each delivery attempt. The default is
+	// DefaultForwardTimeout.
+	Timeout time.Duration
+}
+
+// ForwardResult is the outcome of relaying a webhook to one Endpoint.
+type ForwardResult struct {
+	URL string
+	// StatusCode is the status of the last response, or 0 if none was
+	// received.
+	StatusCode int
+	Attempts   int
+	Err        error
+}
+
+// DeadLetter is a webhook a Forwarder could not deliver to an endpoint.
+type DeadLetter struct {
+	// Result reports the endpoint and how the last attempt failed.
+	Result ForwardResult
+	Body   []byte
+	// Headers are the headers of the original request.
+	Headers  http.Header
+	FailedAt time.Time
+}
+
+// DeadLetterSink stores webhooks a Forwarder gave up on, so they can be
+// inspected or delivered again later. See WithForwardDeadLetter.
+type DeadLetterSink interface {
+	StoreDeadLetter(ctx context.Context, letter DeadLetter) error
+}
+
+// Forwarder relays verified webhooks to a set of downstream endpoints.
+type Forwarder struct {
+	endpoints  []Endpoint
+	client     *http.Client
+	logger     *slog.Logger
+	attempts   int
+	delay      time.Duration
+	deadLetter DeadLetterSink
+	// Headers the signature and timestamp of pass-through deliveries are
+	// read from and sent in.
+	signatureHeader string
+	timestampHeader string
+	// canonicalize is the canonicalizer payloads are re-signed with.
+	canonicalize func(body []byte) ([]byte, error)
+
+	// Background deliveries started by ForwardAsync.
+	ctx    context.Context // canceled by Close once its deadline passes
+	cancel context.CancelFunc
+	mu     sync.Mutex // guards closed and adding to wg
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// ForwarderOption configures a Forwarder.
+type ForwarderOption func(*Forwarder) error
+
+// NewForwarder returns a Forwarder that relays webhooks to endpoints.
+func NewForwarder(endpoints []Endpoint, opts ...ForwarderOption) (*Forwarder, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("webhook: forwarder needs at least one endpoint")
+	}
+	endpoints = slices.Clone(endpoints)
+	for i, e := range endpoints {
+		u, err := url.Parse(e.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhook: invalid endpoint URL %q", e.URL)
+		}
+		if e.Timeout < 0 {
+			return nil, fmt.Errorf("webhook: negative timeout for endpoint %q", e.URL)
+		}
+		if e.Timeout == 0 {
+			endpoints[i].Timeout = DefaultForwardTimeout
+		}
+	}
+
+	f := &Forwarder{
+		endpoints:       endpoints,
+		client:          http.DefaultClient,
+		logger:          slog.New(slog.DiscardHandler),
+		attempts:        1,
+		signatureHeader: DefaultSignatureHeader,
+		timestampHeader: DefaultTimestampHeader,
+	}
+	for _, opt := range opts {
+		if err := opt(f); err != nil {
+			return nil, err
+		}
+	}
+	f.ctx, f.cancel = context.WithCancel(context.Background())
+	return f, nil
+}
+
+// WithForwardClient sets the HTTP client used to reach the endpoints. The
+// default is http.DefaultClient.
+func WithForwardClient(c *http.Client) ForwarderOption {
+	return func(f *Forwarder) error {
+		if c == nil {
+			return errors.New("webhook: forward client must not be nil")
+		}
+		f.client = c
+		return nil
+	}
+}
+
+// WithForwardLogger sets the logger failed deliveries are reported to. By
+// default nothing is logged.
+func WithForwardLogger(l *slog.Logger) ForwarderOption {
+	return func(f *Forwarder) error {
+		if l == nil {
+			return errors.New("webhook: logger must not be nil")
+		}
+		f.logger = withRequestIDs(l)
+		return nil
+	}
+}
+
+// WithForwardRetry makes the Forwarder try each endpoint up to maxAttempts
+// times when the request fails to connect or the endpoint answers with a
+// 5xx or 429 status. Other statuses are final. The wait before each retry
+// doubles starting at baseDelay, with random jitter of up to half of it so
+// that endpoints recovering from an outage are not hit by every sender at
+// once, and is replaced by the endpoint's Retry-After header if it sends
+// one. Waits are capped at MaxForwardRetryDelay. By default each endpoint
+// is tried once.
+func WithForwardRetry(maxAttempts int, baseDelay time.Duration) ForwarderOption {
+	return func(f *Forwarder) error {
+		if maxAttempts <= 0 {
+			return errors.New("webhook: forward attempts must be positive")
+		}
+		if baseDelay < 0 {
+			return errors.New("webhook: forward retry delay must not be negative")
+		}
+		f.attempts = maxAttempts
+		f.delay = baseDelay
+		return nil
+	}
+}
+
+// WithForwardDeadLetter stores webhooks that could not be delivered to an
+// endpoint, once every attempt has failed, in sink. A failure to store
+// them is logged. By default undelivered webhooks are only logged.
+func WithForwardDeadLetter(sink DeadLetterSink) ForwarderOption {
+	return func(f *Forwarder) error {
+		if sink == nil {
+			return errors.New("webhook: dead-letter sink must not be nil")
+		}
+		f.deadLetter = sink
+		return nil
+	}
+}
+
+// WithForwardHeadersFrom passes the signature and timestamp of webhooks
+// through to endpoints without a Key in the headers v reads them from, set
+// with WithSignatureHeader and WithTimestampHeader, rather than in the
+// default x-signature and x-timestamp headers, and re-signs payloads for
+// endpoints with a Key in the canonical form set with
+// WithBodyCanonicalizer, if any. It is needed when the webhooks being
+// forwarded were verified by such a v, and the endpoints verify them the
+// same way.
+func WithForwardHeadersFrom(v *Verifier) ForwarderOption {
+	return func(f *Forwarder) error {
+		if v == nil {
+			return errors.New("webhook: verifier must not be nil")
+		}
+		f.signatureHeader = v.signatureHeader
+		f.timestampHeader = v.timestampHeader
+		f.canonicalize = v.canonicalize
+		return nil
+	}
+}
+
+// Forward posts body to every endpoint concurrently and waits for all of
+// them, including retries. headers are the headers of the original
+// request, whose signature, timestamp, version and delivery id are passed
+// through. A failing endpoint never affects the others; the returned
+// results, in endpoint order, report how each delivery went. Failed
+// deliveries are logged and stored in the dead-letter sink, if any.
+//
+// If ctx is the context of a request that was read signed compressed (see
+// WithGzipSigning), body is its decompressed payload, but the signature
+// covers the compressed bytes: endpoints without a Key get those, with a
+// Content-Encoding: gzip header, so that the signature still matches.
+func (f *Forwarder) Forward(ctx context.Context, body []byte, headers http.Header) []ForwardResult {
+	results := make([]ForwardResult, len(f.endpoints))
+
+	var wg sync.WaitGroup
+	for i, e := range f.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = f.forward(ctx, e, body, headers)
+			if results[i].Err != nil {
+				f.fail(ctx, results[i], body, headers)
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// ForwardAsync is like Forward but delivers body in the background and
+// returns right away, so a webhook can be acknowledged without waiting for
+// slow or retried deliveries. Deliveries keep ctx's values but not its
+// cancellation, since the request context ends as soon as the webhook is
+// acknowledged. body and headers are copied. ForwardAsync returns
+// ErrQueueClosed after Close.
+func (f *Forwarder) ForwardAsync(ctx context.Context, body []byte, headers http.Header) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrQueueClosed
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(f.ctx, cancel)
+	body, headers = bytes.Clone(body), headers.Clone()
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		defer cancel()
+		defer stop()
+		f.Forward(ctx, body, headers)
+	}()
+	return nil
+}
+
+// Close stops accepting deliveries from ForwardAsync and waits until the
+// ones in progress have finished, or until ctx is done. In that case the
+// remaining deliveries are canceled and dead-lettered, and Close returns an
+// error wrapping ctx.Err().
+func (f *Forwarder) Close(ctx context.Context) error {
+	f.mu.Lock()
+	f.closed = true
+	f.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		f.cancel()
+		return nil
+	case <-ctx.Done():
+		f.cancel()
+		<-done
+		return fmt.Errorf("webhook: forwarding canceled: %w", ctx.Err())
+	}
+}
+
+// fail logs an undelivered webhook and stores it in the dead-letter sink.
+func (f *Forwarder) fail(ctx context.Context, result ForwardResult, body []byte, headers http.Header) {
+	f.logger.WarnContext(ctx, "Failed to forward webhook",
+		"url", result.URL, "attempts", result.Attempts, "error", result.Err)
+	if f.deadLetter == nil {
+		return
+	}
+
+	letter := DeadLetter{Result: result, Body: body, Headers: headers, FailedAt: time.Now()}
+	// The delivery may have failed because ctx was canceled, which must not
+	// prevent storing it.
+	if err := f.deadLetter.StoreDeadLetter(context.WithoutCancel(ctx), letter); err != nil {
+		f.logger.ErrorContext(ctx, "Failed to store undelivered webhook", "url", result.URL, "error", err)
+	}
+}
+
+func (f *Forwarder) forward(ctx context.Context, e Endpoint, body []byte, headers http.Header) ForwardResult {
+	result := ForwardResult{URL: e.URL}
+	var wait time.Duration
+	for result.Attempts < f.attempts {
+		if result.Attempts > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				result.Err = ctx.Err()
+				return result
+			}
+		}
+		result.Attempts++
+
+		var retry bool
+		var retryAfter time.Duration
+		result.StatusCode, retryAfter, retry, result.Err = f.post(ctx, e, body, headers)
+		if !retry || ctx.Err() != nil {
+			break
+		}
+		wait = retryAfter
+		if wait < 0 {
+			wait = f.backoff(result.Attempts)
+		}
+	}
+	return result
+}
+
+// backoff returns how long to wait after the given number of failed
+// attempts: baseDelay doubled for each attempt after the first, plus up to
+// half of that again as jitter.
+func (f *Forwarder) backoff(attempts int) time.Duration {
+	d := f.delay
+	for range attempts - 1 {
+		if d >= MaxForwardRetryDelay {
+			break
+		}
+		d *= 2
+	}
+	if d > 0 {
+		d += rand.N(d/2 + 1)
+	}
+	return min(d, MaxForwardRetryDelay)
+}
+
+// retryAfter parses a Retry-After header, which holds either a number of
+// seconds or an HTTP date. It returns -1 if there is no valid header.
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return -1
+	}
+	if s, err := strconv.Atoi(header); err == nil {
+		if s < 0 {
+			return -1
+		}
+		return min(time.Duration(s)*time.Second, MaxForwardRetryDelay)
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return min(max(t.Sub(now), 0), MaxForwardRetryDelay)
+	}
+	return -1
+}
+
+// signedBody returns the bytes a re-signed payload's signature covers.
+// Payloads that cannot be canonicalized are signed as they are.
+func (f *Forwarder) signedBody(body []byte) []byte {
+	if f.canonicalize == nil {
+		return body
+	}
+	if signed, err := f.canonicalize(body); err == nil {
+		return signed
+	}
+	return body
+}
+
+// post makes one delivery attempt and reports whether it may be retried,
+// and after how long if the endpoint said so with Retry-After (-1
+// otherwise).
+func (f *Forwarder) post(ctx context.Context, e Endpoint, body []byte, headers http.Header) (int, time.Duration, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
+	defer cancel()
+
+	payload, encoding := body, ""
+	if d := deliveryFromContext(ctx); e.Key == "" && d != nil && d.compressed != nil {
+		payload, encoding = d.compressed, "gzip"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, -1, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	if id := headers.Get(deliveryIDHeader); id != "" {
+		req.Header.Set(deliveryIDHeader, id)
+	}
+	// The version is signed, so it must reach the endpoint either way.
+	version := headers.Get(versionHeader)
+	if version != "" {
+		req.Header.Set(versionHeader, version)
+	}
+	if e.Key != "" {
+		signature, timestamp := SignVersion(e.Key, f.signedBody(body), time.Now(), version)
+		req.Header.Set(DefaultSignatureHeader, signature)
+		req.Header.Set(DefaultTimestampHeader, timestamp)
+	} else {
+		req.Header.Set(f.signatureHeader, headers.Get(f.signatureHeader))
+		req.Header.Set(f.timestampHeader, headers.Get(f.timestampHeader))
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0, -1, true, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return resp.StatusCode, retryAfter(resp.Header.Get("Retry-After"), time.Now()), retry,
+			fmt.Errorf("webhook: endpoint answered %d", resp.StatusCode)
+	}
+	return resp.StatusCode, -1, false, nil
+}
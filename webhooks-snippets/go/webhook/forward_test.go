@@ -0,0 +1,364 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestForwarder(t *testing.T) {
+	body := []byte(testBody)
+	const downstreamKey = "downstream-key"
+	downstream := mustVerifier(t, downstreamKey)
+
+	resigned := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		if err := downstream.Verify(got, r.Header); err != nil {
+			t.Errorf("re-signed request: Verify() = %v", err)
+		}
+	}))
+	defer resigned.Close()
+
+	passthrough := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		if err := mustVerifier(t, testKey).Verify(got, r.Header); err != nil {
+			t.Errorf("passed-through request: Verify() = %v", err)
+		}
+	}))
+	defer passthrough.Close()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	f, err := NewForwarder([]Endpoint{
+		{URL: resigned.URL, Key: downstreamKey},
+		{URL: passthrough.URL},
+		{URL: down.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := f.Forward(context.Background(), body, signedHeaders(testKey, body, time.Now()))
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for _, r := range results[:2] {
+		if r.Err != nil || r.StatusCode != http.StatusOK {
+			t.Errorf("%s: status = %d, err = %v, want 200", r.URL, r.StatusCode, r.Err)
+		}
+	}
+	if r := results[2]; r.Err == nil || r.StatusCode != http.StatusBadGateway {
+		t.Errorf("failing endpoint: status = %d, err = %v, want 502 and an error", r.StatusCode, r.Err)
+	}
+}
+
+func TestForwarderVersionedCustomHeaders(t *testing.T) {
+	body := []byte(testBody)
+	const downstreamKey = "downstream-key"
+	upstream := mustVerifier(t, testKey,
+		WithSignatureHeader("x-meet-signature"),
+		WithTimestampHeader("x-meet-timestamp"),
+	)
+
+	verifyWith := func(v *Verifier) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			got, _ := io.ReadAll(r.Body)
+			if version := r.Header.Get("x-webhook-version"); version != "2" {
+				t.Errorf("%s: version = %q, want %q", r.Host, version, "2")
+			}
+			if err := v.Verify(got, r.Header); err != nil {
+				t.Errorf("%s: Verify() = %v", r.Host, err)
+			}
+		}
+	}
+	resigned := httptest.NewServer(verifyWith(mustVerifier(t, downstreamKey)))
+	defer resigned.Close()
+	passthrough := httptest.NewServer(verifyWith(upstream))
+	defer passthrough.Close()
+
+	f, err := NewForwarder([]Endpoint{
+		{URL: resigned.URL, Key: downstreamKey},
+		{URL: passthrough.URL},
+	}, WithForwardHeadersFrom(upstream))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature, timestamp := SignVersion(testKey, body, time.Now(), "2")
+	headers := http.Header{}
+	headers.Set("x-meet-signature", signature)
+	headers.Set("x-meet-timestamp", timestamp)
+	headers.Set("x-webhook-version", "2")
+	if err := upstream.Verify(body, headers); err != nil {
+		t.Fatalf("Verify() on the original request = %v", err)
+	}
+	for _, r := range f.Forward(context.Background(), body, headers) {
+		if r.Err != nil {
+			t.Errorf("%s: err = %v", r.URL, r.Err)
+		}
+	}
+}
+
+func TestForwarderSignedCompressed(t *testing.T) {
+	body := []byte(testBody)
+	compressed := gzipped(t, body)
+	const downstreamKey = "downstream-key"
+	upstream := mustVerifier(t, testKey, WithGzipSigning(SignedCompressed))
+
+	verifyWith := func(v *Verifier) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if _, err := v.ReadVerified(r); err != nil {
+				t.Errorf("%s: ReadVerified() = %v", r.Host, err)
+			}
+		}
+	}
+	resigned := httptest.NewServer(verifyWith(mustVerifier(t, downstreamKey)))
+	defer resigned.Close()
+	passthrough := httptest.NewServer(verifyWith(mustVerifier(t, testKey, WithGzipSigning(SignedCompressed))))
+	defer passthrough.Close()
+
+	f, err := NewForwarder([]Endpoint{
+		{URL: resigned.URL, Key: downstreamKey},
+		{URL: passthrough.URL},
+	}, WithForwardHeadersFrom(upstream))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := PassthroughHandler(upstream, func(w http.ResponseWriter, r *http.Request, got []byte) {
+		if !bytes.Equal(got, body) {
+			t.Errorf("body = %q, want it decompressed", got)
+		}
+		for _, result := range f.Forward(r.Context(), got, r.Header) {
+			if result.Err != nil {
+				t.Errorf("%s: err = %v", result.URL, result.Err)
+			}
+		}
+	})
+	headers := signedHeaders(testKey, compressed, time.Now())
+	headers.Set("Content-Encoding", "gzip")
+	if rec := serve(h, newRequest(compressed, headers)); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+}
+
+func TestForwarderResignsCanonicalBody(t *testing.T) {
+	body := []byte(`{ "event": "meetingStarted", "data": {"roomId": "room-1"} }`)
+	const downstreamKey = "downstream-key"
+	upstream := mustVerifier(t, testKey, WithBodyCanonicalizer(CanonicalJSON))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		if err := mustVerifier(t, downstreamKey, WithBodyCanonicalizer(CanonicalJSON)).Verify(got, r.Header); err != nil {
+			t.Errorf("Verify() = %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	f, err := NewForwarder([]Endpoint{{URL: srv.URL, Key: downstreamKey}}, WithForwardHeadersFrom(upstream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := f.Forward(context.Background(), body, http.Header{})[0]; r.Err != nil {
+		t.Errorf("Forward() = %v", r.Err)
+	}
+}
+
+func TestForwarderRetry(t *testing.T) {
+	var calls atomic.Int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer flaky.Close()
+
+	f, err := NewForwarder([]Endpoint{{URL: flaky.URL, Key: testKey}}, WithForwardRetry(3, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := f.Forward(context.Background(), []byte(testBody), http.Header{})[0]
+	if r.Err != nil || r.Attempts != 3 {
+		t.Errorf("result = %+v, want success after 3 attempts", r)
+	}
+}
+
+func TestForwarderRetryStatuses(t *testing.T) {
+	tests := []struct {
+		status       int
+		wantAttempts int
+	}{
+		{http.StatusTooManyRequests, 3},
+		{http.StatusBadGateway, 3},
+		{http.StatusBadRequest, 1},
+		{http.StatusUnauthorized, 1},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			// Retry-After overrides a backoff that would time the test out.
+			f, err := NewForwarder([]Endpoint{{URL: srv.URL}}, WithForwardRetry(3, time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := f.Forward(context.Background(), []byte(testBody), http.Header{})[0]
+			if r.Err == nil || r.StatusCode != tt.status || r.Attempts != tt.wantAttempts {
+				t.Errorf("result = %+v, want status %d after %d attempts", r, tt.status, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+type deadLetters struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+func (d *deadLetters) StoreDeadLetter(ctx context.Context, letter DeadLetter) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.letters = append(d.letters, letter)
+	return nil
+}
+
+func TestForwarderDeadLetter(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	sink := &deadLetters{}
+	f, err := NewForwarder([]Endpoint{{URL: down.URL}}, WithForwardRetry(2, time.Millisecond), WithForwardDeadLetter(sink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := signedHeaders(testKey, []byte(testBody), time.Now())
+	f.Forward(context.Background(), []byte(testBody), headers)
+
+	if len(sink.letters) != 1 {
+		t.Fatalf("got %d dead letters, want 1", len(sink.letters))
+	}
+	letter := sink.letters[0]
+	if letter.Result.URL != down.URL || letter.Result.Attempts != 2 || string(letter.Body) != testBody ||
+		letter.Headers.Get("x-signature") != headers.Get("x-signature") {
+		t.Errorf("dead letter = %+v, want the webhook after 2 attempts to %s", letter, down.URL)
+	}
+}
+
+func TestForwarderAsync(t *testing.T) {
+	release := make(chan struct{})
+	var delivered atomic.Int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+			delivered.Add(1)
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+
+	sink := &deadLetters{}
+	f, err := NewForwarder([]Endpoint{{URL: slow.URL}}, WithForwardDeadLetter(sink))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The request context ending must not cancel the delivery.
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := f.ForwardAsync(ctx, []byte(testBody), http.Header{}); err != nil {
+		t.Fatalf("ForwardAsync() = %v, want nil", err)
+	}
+	cancel()
+	close(release)
+	if err := f.Close(context.Background()); err != nil {
+		t.Errorf("Close() = %v, want nil", err)
+	}
+	if delivered.Load() != 1 || len(sink.letters) != 0 {
+		t.Errorf("delivered %d, dead-lettered %d, want 1 and 0", delivered.Load(), len(sink.letters))
+	}
+	if err := f.ForwardAsync(context.Background(), []byte(testBody), http.Header{}); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("ForwardAsync() after Close = %v, want %v", err, ErrQueueClosed)
+	}
+}
+
+func TestForwarderCloseDeadline(t *testing.T) {
+	stuck := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Client disconnects are only noticed once the body has been read.
+		io.ReadAll(r.Body)
+		<-r.Context().Done()
+	}))
+	defer stuck.Close()
+
+	sink := &deadLetters{}
+	f, err := NewForwarder([]Endpoint{{URL: stuck.URL, Timeout: time.Minute}}, WithForwardDeadLetter(sink))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.ForwardAsync(context.Background(), []byte(testBody), http.Header{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := f.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if len(sink.letters) != 1 {
+		t.Errorf("got %d dead letters, want the canceled delivery", len(sink.letters))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", -1},
+		{"3", 3 * time.Second},
+		{"-1", -1},
+		{"soon", -1},
+		{now.Add(10 * time.Second).Format(http.TimeFormat), 10 * time.Second},
+		{now.Add(-time.Second).Format(http.TimeFormat), 0},
+		{"86400", MaxForwardRetryDelay},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.header, now); got != tt.want {
+			t.Errorf("retryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestForwarderBackoff(t *testing.T) {
+	f := &Forwarder{delay: 100 * time.Millisecond}
+	for attempts, base := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond} {
+		for range 20 {
+			if got := f.backoff(attempts); got < base || got > base+base/2 {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", attempts, got, base, base+base/2)
+			}
+		}
+	}
+	if got := f.backoff(100); got != MaxForwardRetryDelay {
+		t.Errorf("backoff(100) = %v, want %v", got, MaxForwardRetryDelay)
+	}
+}
+
+func TestNewForwarderRejectsInvalidURL(t *testing.T) {
+	if _, err := NewForwarder([]Endpoint{{URL: "not a url"}}); err == nil {
+		t.Fatal("NewForwarder() with invalid URL succeeded, want error")
+	}
+}
@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GzipSigning says which bytes of a gzip-compressed webhook the signature
+// covers.
+type GzipSigning int
+
+const (
+	// SignedDecompressed verifies the signature over the decompressed body.
+	// OpenVidu Meet signs the JSON body before any compression applied on
+	// the way, for example by a gateway, so this is the default.
+	SignedDecompressed GzipSigning = iota
+	// SignedCompressed verifies the signature over the compressed bytes as
+	// received, for senders that sign after compressing.
+	SignedCompressed
+)
+
+func (s GzipSigning) String() string {
+	switch s {
+	case SignedDecompressed:
+		return "decompressed"
+	case SignedCompressed:
+		return "compressed"
+	default:
+		return fmt.Sprintf("GzipSigning(%d)", int(s))
+	}
+}
+
+// contentEncoding returns the lowercased Content-Encoding of a request, or
+// "" for an uncompressed one.
+func contentEncoding(value string) (string, error) {
+	enc := strings.ToLower(strings.TrimSpace(value))
+	switch enc {
+	case "", "identity":
+		return "", nil
+	case "gzip", "x-gzip":
+		return "gzip", nil
+	default:
+		return "", fmt.Errorf("%w: content encoding %q", ErrUnsupportedMediaType, value)
+	}
+}
+
+// gunzip returns a reader of the decompressed contents of r. Malformed
+// input makes it fail with ErrInvalidEncoding.
+func gunzip(r io.Reader) (io.Reader, error) {
+	zr, err := gzip.NewReader(r)
+	if err == io.EOF {
+		return nil, ErrEmptyBody
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	return gzipErrReader{zr}, nil
+}
+
+type gzipErrReader struct{ r io.Reader }
+
+func (g gzipErrReader) Read(p []byte) (int, error) {
+	n, err := g.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	return n, err
+}
+
+// decompress returns the decompressed body, rejecting it with
+// ErrBodyTooLarge once it exceeds limit.
+func decompress(body []byte, limit int64) ([]byte, error) {
+	zr, err := gunzip(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return readBody(zr, limit)
+}
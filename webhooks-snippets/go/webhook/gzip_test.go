@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func gzipped(t *testing.T, b []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadRequestGzip(t *testing.T) {
+	body := []byte(testBody)
+	compressed := gzipped(t, body)
+	bomb := gzipped(t, append([]byte(`{"event":"meetingStarted","data":{"roomId":"`), make([]byte, 4<<20)...))
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		body     []byte
+		signed   []byte
+		encoding string
+		want     int
+	}{
+		{"signed decompressed", nil, compressed, body, "gzip", http.StatusOK},
+		{"signed compressed", []Option{WithGzipSigning(SignedCompressed)}, compressed, compressed, "gzip", http.StatusOK},
+		{"signature over wrong bytes", nil, compressed, compressed, "gzip", http.StatusUnauthorized},
+		{"malformed gzip", nil, []byte("not gzip"), []byte("not gzip"), "gzip", http.StatusBadRequest},
+		{"truncated gzip", nil, compressed[:len(compressed)-4], body, "gzip", http.StatusBadRequest},
+		{"decompressed size limited", nil, bomb, bomb, "gzip", http.StatusRequestEntityTooLarge},
+		{"unsupported encoding", nil, body, body, "br", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := mustVerifier(t, testKey, tt.opts...)
+			var got []byte
+			h := Handler(v, func(w http.ResponseWriter, r *http.Request, event *WebhookEvent) {
+				got = []byte(event.RoomID)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := newRequest(tt.body, signedHeaders(testKey, tt.signed, time.Now()))
+			req.Header.Set("Content-Encoding", tt.encoding)
+			rec := serve(h, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want == http.StatusOK && string(got) != "room-1" {
+				t.Errorf("room id = %q, want %q", got, "room-1")
+			}
+		})
+	}
+}
+
+func TestReadRequestGzipHeadersFirst(t *testing.T) {
+	v := mustVerifier(t, testKey)
+
+	req := newRequest([]byte("not gzip"), http.Header{})
+	req.Header.Set("Content-Encoding", "gzip")
+	if _, _, err := v.ReadRequest(req); !errors.Is(err, ErrMissingSignature) {
+		t.Errorf("err = %v, want %v before the body is decompressed", err, ErrMissingSignature)
+	}
+}
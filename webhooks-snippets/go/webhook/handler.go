@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// RunHandler calls handler with event and waits for it to return or for ctx
+// to be done, whichever happens first. If a handler timeout is configured
+// (see WithHandlerTimeout) ctx is given that deadline, and exceeding it
+// returns ErrHandlerTimeout. The handler is expected to return promptly once
+// its context is done; RunHandler does not wait for it after that.
+//
+// A panic in handler is recovered, logged with its stack trace, counted if
+// v's metrics implement PanicMetrics, and returned as a *PanicError, which
+// StatusCode maps to 500.
+func (v *Verifier) RunHandler(ctx context.Context, event *WebhookEvent, handler HandlerFunc) error {
+	if v.handlerTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.handlerTimeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		err := callHandler(ctx, handler, event)
+		// Reported here, since RunHandler may have returned already.
+		var pe *PanicError
+		if errors.As(err, &pe) {
+			reportPanic(ctx, v.logger, v.metrics, pe)
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrHandlerTimeout
+		}
+		return fmt.Errorf("%w: %v", ErrHandlerCanceled, ctx.Err())
+	}
+}
+
+// DispatchHandler returns a net/http handler that verifies and parses
+// incoming webhooks with v and processes them synchronously with handler,
+// using the request context. It answers as set with WithSuccessStatus,
+// 200 by default, once handler succeeds, 503 if it times out or the
+// request is canceled, and 500 if it fails.
+func DispatchHandler(v *Verifier, handler HandlerFunc) http.HandlerFunc {
+	return Handler(v, func(w http.ResponseWriter, r *http.Request, event *WebhookEvent) {
+		if err := v.RunHandler(r.Context(), event, handler); err != nil {
+			v.logger.ErrorContext(r.Context(), "Failed to handle webhook", "event", event.Type, "error", err)
+			v.WriteError(w, r, err)
+			return
+		}
+		v.WriteSuccess(w)
+	})
+}
+
+// BatchHandler is like DispatchHandler but accepts batched deliveries (see
+// Verifier.ReadBatch) and handles their events in order, as HandleBatch
+// does with mode. It answers with the success status only if every event
+// was handled.
+func BatchHandler(v *Verifier, handler HandlerFunc, mode BatchMode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r = AttachRequestID(r)
+		events, _, err := v.ReadBatch(r)
+		if err != nil {
+			v.writeRejection(w, r, err)
+			return
+		}
+
+		v.serveDelivery(w, r, func(w http.ResponseWriter) {
+			err := HandleBatch(r.Context(), events, func(ctx context.Context, event *WebhookEvent) error {
+				return v.RunHandler(ctx, event, handler)
+			}, mode)
+			if err != nil {
+				v.logger.ErrorContext(r.Context(), "Failed to handle webhook batch", "events", len(events), "error", err)
+				v.WriteError(w, r, err)
+				return
+			}
+			v.WriteSuccess(w)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunHandlerCancel(t *testing.T) {
+	v := mustVerifier(t, testKey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	aborted := make(chan struct{})
+	blocked := func(ctx context.Context, event *WebhookEvent) error {
+		<-ctx.Done()
+		close(aborted)
+		return ctx.Err()
+	}
+
+	result := make(chan error, 1)
+	go func() { result <- v.RunHandler(ctx, &WebhookEvent{}, blocked) }()
+	cancel()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrHandlerCanceled) {
+			t.Errorf("RunHandler() = %v, want %v", err, ErrHandlerCanceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RunHandler did not return after its context was canceled")
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatal("handler context was not canceled")
+	}
+}
+
+func TestDispatchHandlerTimeout(t *testing.T) {
+	body := []byte(testBody)
+	v := mustVerifier(t, testKey, WithHandlerTimeout(10*time.Millisecond))
+
+	h := DispatchHandler(v, func(ctx context.Context, event *WebhookEvent) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	rec := serve(h, newRequest(body, signedHeaders(testKey, body, time.Now())))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestDispatchHandlerError(t *testing.T) {
+	body := []byte(testBody)
+	v := mustVerifier(t, testKey)
+
+	h := DispatchHandler(v, func(ctx context.Context, event *WebhookEvent) error {
+		return errors.New("database unavailable")
+	})
+
+	rec := serve(h, newRequest(body, signedHeaders(testKey, body, time.Now())))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if strings.Contains(rec.Body.String(), "database") || !strings.Contains(rec.Body.String(), http.StatusText(http.StatusInternalServerError)) {
+		t.Errorf("body = %s, want only the status text", rec.Body)
+	}
+}
+
+func TestDispatchHandlerSuccessResponse(t *testing.T) {
+	body := []byte(testBody)
+	handler := func(ctx context.Context, event *WebhookEvent) error { return nil }
+
+	v := mustVerifier(t, testKey, WithSuccessStatus(http.StatusNoContent))
+	rec := serve(DispatchHandler(v, handler), newRequest(body, signedHeaders(testKey, body, time.Now())))
+	if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+		t.Errorf("response = %d %q, want 204 with no body", rec.Code, rec.Body)
+	}
+
+	v = mustVerifier(t, testKey, WithSuccessStatus(http.StatusAccepted), WithSuccessResponse([]byte(`{"ack":true}`), "application/json"))
+	rec = serve(DispatchHandler(v, handler), newRequest(body, signedHeaders(testKey, body, time.Now())))
+	if rec.Code != http.StatusAccepted || rec.Body.String() != `{"ack":true}` || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("response = %d %q (%s), want 202 with the acknowledgment", rec.Code, rec.Body, rec.Header().Get("Content-Type"))
+	}
+}
+
+type ignoredCounter struct {
+	noopMetrics
+	ignored atomic.Int32
+}
+
+func (c *ignoredCounter) IncIgnored(string) { c.ignored.Add(1) }
+
+func TestDispatchHandlerAllowedEventTypes(t *testing.T) {
+	body := []byte(testBody)
+	store := NewMemoryReplayStore(time.Minute)
+	defer store.Close()
+	replay := NewMemoryReplayStore(time.Minute)
+	defer replay.Close()
+	metrics := &ignoredCounter{}
+	v := mustVerifier(t, testKey,
+		WithAllowedEventTypes(EventRecordingEnded),
+		WithReplayCache(replay),
+		WithDedupeByPayload(store, time.Minute),
+		WithMetrics(metrics),
+	)
+
+	h := DispatchHandler(v, func(ctx context.Context, event *WebhookEvent) error {
+		t.Errorf("handler called for ignored %s event", event.Type)
+		return nil
+	})
+	headers := signedHeaders(testKey, body, time.Now())
+	for range 2 {
+		rec := serve(h, newRequest(body, headers))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+	}
+	// Neither the replay nor the duplicate check ran, so the retry was
+	// ignored too.
+	if got := metrics.ignored.Load(); got != 2 {
+		t.Errorf("ignored %d events, want 2", got)
+	}
+
+	// Forged webhooks are rejected whatever their type.
+	rec := serve(h, newRequest(body, signedHeaders("other-key", body, time.Now())))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("forged webhook status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestBatchHandlerIgnoredBatchRetry(t *testing.T) {
+	body := []byte(`[{"event":"meetingStarted","data":{"roomId":"room-1"}}]`)
+	replay := NewMemoryReplayStore(time.Minute)
+	defer replay.Close()
+	v := mustVerifier(t, testKey, WithAllowedEventTypes(EventRecordingEnded), WithReplayCache(replay))
+
+	h := BatchHandler(v, func(ctx context.Context, event *WebhookEvent) error {
+		t.Errorf("handler called for ignored %s event", event.Type)
+		return nil
+	}, BatchAbort)
+	headers := signedHeaders(testKey, body, time.Now())
+	for i := range 2 {
+		if rec := serve(h, newRequest(body, headers)); rec.Code != http.StatusOK {
+			t.Errorf("delivery %d: status = %d, want %d: %s", i, rec.Code, http.StatusOK, rec.Body)
+		}
+	}
+}
+
+func TestBatchHandler(t *testing.T) {
+	body := []byte(`[
+		{"event":"meetingStarted","data":{"roomId":"room-1"}},
+		{"event":"meetingStarted","data":{"roomId":"room-2"}},
+		{"event":"meetingEnded","data":{"roomId":"room-3"}}
+	]`)
+
+	tests := []struct {
+		mode BatchMode
+		want []string
+	}{
+		{BatchAbort, []string{"room-1", "room-2"}},
+		{BatchContinue, []string{"room-1", "room-2", "room-3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			v := mustVerifier(t, testKey)
+			var handled []string
+			h := BatchHandler(v, func(ctx context.Context, event *WebhookEvent) error {
+				handled = append(handled, event.RoomID)
+				if event.RoomID == "room-2" {
+					return errors.New("boom")
+				}
+				return nil
+			}, tt.mode)
+
+			rec := serve(h, newRequest(body, signedHeaders(testKey, body, time.Now())))
+			if rec.Code != http.StatusInternalServerError {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+			}
+			if !slices.Equal(handled, tt.want) {
+				t.Errorf("handled %v, want %v", handled, tt.want)
+			}
+		})
+	}
+}
+
+// panicCounter is Metrics and QueueMetrics that only count handler panics.
+type panicCounter struct {
+	noopMetrics
+	overflowCounter
+	panics atomic.Int32
+}
+
+func (c *panicCounter) IncHandlerPanic(string) { c.panics.Add(1) }
+
+func TestDispatchHandlerPanic(t *testing.T) {
+	body := []byte(testBody)
+	var logs bytes.Buffer
+	metrics := &panicCounter{}
+	v := mustVerifier(t, testKey, WithMetrics(metrics), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+	h := DispatchHandler(v, func(ctx context.Context, event *WebhookEvent) error {
+		panic("boom")
+	})
+
+	rec := serve(h, newRequest(body, signedHeaders(testKey, body, time.Now())))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if strings.Contains(rec.Body.String(), "boom") {
+		t.Errorf("body = %s, want the panic value left out", rec.Body)
+	}
+	if metrics.panics.Load() != 1 {
+		t.Errorf("counted %d panics, want 1", metrics.panics.Load())
+	}
+	if out := logs.String(); !strings.Contains(out, "event=meetingStarted") || !strings.Contains(out, "TestDispatchHandlerPanic") {
+		t.Errorf("log = %q, want the event type and a stack trace", out)
+	}
+}
@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// HashAlgorithm is the hash function used to compute the HMAC signature.
+type HashAlgorithm int
+
+const (
+	// HashSHA256 is HMAC-SHA256. OpenVidu Meet signs webhooks with
+	// HMAC-SHA256, so this is the default.
+	HashSHA256 HashAlgorithm = iota
+	// HashSHA512 is HMAC-SHA512.
+	HashSHA512
+)
+
+func (h HashAlgorithm) String() string {
+	switch h {
+	case HashSHA256:
+		return "sha256"
+	case HashSHA512:
+		return "sha512"
+	default:
+		return fmt.Sprintf("HashAlgorithm(%d)", int(h))
+	}
+}
+
+func (h HashAlgorithm) new() func() hash.Hash {
+	switch h {
+	case HashSHA512:
+		return sha512.New
+	default:
+		return sha256.New
+	}
+}
+
+// stripAlgorithmPrefix removes an "alg=" prefix such as "sha256=" from
+// signature, as added by some relays and GitHub-style signers, and checks
+// that it names alg. Signatures without a prefix are returned unchanged.
+func stripAlgorithmPrefix(signature string, alg string) (string, error) {
+	name, rest, ok := strings.Cut(signature, "=")
+	// Base64 padding also uses '=', but only at the end of the value.
+	if !ok || strings.Trim(rest, "=") == "" || !isAlgorithmName(name) {
+		return signature, nil
+	}
+
+	if !strings.EqualFold(name, alg) {
+		return "", fmt.Errorf("%w: signature uses %q, expected %q", ErrUnsupportedAlgorithm, name, alg)
+	}
+	return rest, nil
+}
+
+func isAlgorithmName(s string) bool {
+	for _, r := range s {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,519 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReadRequest reads r's body, verifies it with v and parses it into an event.
+// It returns the raw body alongside the event so callers can keep it.
+// Requests over the rate limit (see WithRateLimit), with an unexpected
+// Content-Type (see WithRequireContentType), from an address outside the
+// allowlist (see WithAllowedCIDRs) or with a body larger than the
+// configured maximum are rejected before any signature work is done. If the
+// body was read but failed verification or parsing, it is returned along
+// with the error.
+//
+// Bodies sent with "Content-Encoding: gzip" are decompressed, and the
+// decompressed body is returned. Which bytes the signature is checked
+// against is set with WithGzipSigning, while the maximum body size always
+// applies to the decompressed size.
+func (v *Verifier) ReadRequest(r *http.Request) (*WebhookEvent, []byte, error) {
+	events, body, err := v.read(r, readSingle)
+	if err != nil {
+		return nil, body, err
+	}
+	return events[0], body, nil
+}
+
+// ReadBatch is like ReadRequest but also accepts a body holding a JSON
+// array of events, as sent for batched deliveries; see ParseEvents. The
+// signature covers the whole body.
+func (v *Verifier) ReadBatch(r *http.Request) ([]*WebhookEvent, []byte, error) {
+	return v.read(r, readBatch)
+}
+
+// ReadVerified is like ReadRequest but stops once the body is verified, for
+// relays that forward the body untouched and have no use for the events:
+// the body is not parsed, so the checks ReadRequest makes on parsed events,
+// such as validators, and sinks are skipped. Events of a type not allowed
+// by WithAllowedEventTypes are still ignored for JSON object bodies, whose
+// type is read without parsing the rest of the body. See PassthroughHandler.
+func (v *Verifier) ReadVerified(r *http.Request) ([]byte, error) {
+	_, body, err := v.read(r, readPassthrough)
+	return body, err
+}
+
+// readMode is what read does with a verified body.
+type readMode int
+
+const (
+	readSingle      readMode = iota // parse it as a single event
+	readBatch                       // parse it as one or more events
+	readPassthrough                 // return it unparsed
+)
+
+func (v *Verifier) read(r *http.Request, mode readMode) ([]*WebhookEvent, []byte, error) {
+	r = AttachRequestID(r)
+	start := v.clock.Now()
+	v.metrics.IncReceived()
+
+	events, body, err := v.readRequest(r, mode)
+
+	v.metrics.ObserveLatency(v.clock.Now().Sub(start))
+	if err != nil {
+		// Events only found to be ignored once parsed, such as batches or
+		// gzip bodies signed compressed, were already recorded.
+		v.Release(r)
+	}
+	if err != nil && !errors.Is(err, ErrEventIgnored) {
+		v.metrics.IncRejected(Reason(err))
+		v.storeRejected(r, body, err)
+	}
+	v.logResult(r, events, body, err)
+	return events, body, err
+}
+
+func (v *Verifier) readRequest(r *http.Request, mode readMode) ([]*WebhookEvent, []byte, error) {
+	if v.allowedNets != nil && !v.addressAllowed(v.clientIP(r)) {
+		return nil, nil, ErrForbiddenAddress
+	}
+
+	if v.limiter != nil && !v.limiter.allow(v.clientIP(r), v.clock.Now()) {
+		return nil, nil, ErrRateLimited
+	}
+
+	if v.contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || !strings.EqualFold(mediaType, v.contentType) {
+			return nil, nil, ErrUnsupportedMediaType
+		}
+	}
+
+	// Bodies of unknown length are still limited while they are read.
+	if r.ContentLength > v.maxBodySize {
+		return nil, nil, ErrBodyTooLarge
+	}
+
+	encoding, err := contentEncoding(r.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Requests built by hand, rather than by net/http, may have no body.
+	reqBody := r.Body
+	if reqBody == nil {
+		reqBody = http.NoBody
+	}
+
+	verifyStart := v.clock.Now()
+	var body []byte
+	switch {
+	case encoding == "":
+		body, err = v.verifyReader(r.Context(), reqBody, r.Header, r.ContentLength)
+	case v.gzipSigning == SignedCompressed:
+		body, err = v.verifyReader(r.Context(), reqBody, r.Header, r.ContentLength)
+		if err == nil {
+			if d := deliveryFromContext(r.Context()); d != nil {
+				d.compressed = body
+			}
+			body, err = decompress(body, v.maxBodySize)
+		}
+	default:
+		// The headers are checked before anything is decompressed, so
+		// unsigned requests are refused as such whatever their body.
+		var (
+			sig  *requestSignature
+			keys *keySet
+			zr   io.Reader
+		)
+		if sig, keys, err = v.signatureHeaders(r.Header); err == nil {
+			if zr, err = gunzip(reqBody); err == nil {
+				body, err = v.verifyBody(r.Context(), zr, r.Header, -1, sig, keys)
+			}
+		}
+	}
+	if err != nil {
+		v.observeVerification(nil, body, verifyStart)
+		return nil, body, err
+	}
+	if mode == readPassthrough {
+		if v.verifyMetrics != nil {
+			v.verifyMetrics.ObserveVerification("passthrough", v.clock.Now().Sub(verifyStart), len(body))
+		}
+		return nil, body, nil
+	}
+
+	var events []*WebhookEvent
+	var raws []json.RawMessage
+	if mode == readBatch {
+		events, raws, err = parseBatch(body)
+	} else {
+		var event *WebhookEvent
+		event, err = ParseEvent(body)
+		events, raws = []*WebhookEvent{event}, []json.RawMessage{body}
+	}
+	if err != nil {
+		v.observeVerification(nil, body, verifyStart)
+		return nil, body, err
+	}
+	v.observeVerification(events, body, verifyStart)
+	if v.allowedTypes != nil {
+		if events, raws = v.allowedEvents(events, raws); len(events) == 0 {
+			return nil, body, ErrEventIgnored
+		}
+	}
+
+	for i, event := range events {
+		if err := v.accept(r, event, raws[i]); err != nil {
+			if mode == readBatch {
+				err = fmt.Errorf("event %d: %w", i, err)
+			}
+			return nil, body, err
+		}
+	}
+	return events, body, nil
+}
+
+func (v *Verifier) observeVerification(events []*WebhookEvent, body []byte, start time.Time) {
+	if v.verifyMetrics == nil || body == nil {
+		return
+	}
+	eventType := "unknown"
+	switch len(events) {
+	case 0:
+	case 1:
+		eventType = string(events[0].Type)
+	default:
+		eventType = "batch"
+	}
+	v.verifyMetrics.ObserveVerification(eventType, v.clock.Now().Sub(start), len(body))
+}
+
+// accept runs the checks and side effects configured for a parsed event
+// whose raw JSON is raw.
+func (v *Verifier) accept(r *http.Request, event *WebhookEvent, raw []byte) error {
+	event.RequestID = RequestIDFromContext(r.Context())
+	if err := v.checkEvent(event, raw, r.Header); err != nil {
+		return err
+	}
+
+	if v.sink != nil {
+		if err := v.sink.Store(r.Context(), event, raw); err != nil {
+			v.logger.ErrorContext(r.Context(), "Failed to store webhook", "event", event.Type, "error", err)
+			if v.sinkFailure == SinkFailureReject {
+				return fmt.Errorf("%w: %v", ErrSinkFailed, err)
+			}
+		}
+	}
+	if v.ordering != nil {
+		v.ordering.check(event)
+	}
+	return nil
+}
+
+// checkEvent fills in the fields of a parsed event that come from the
+// (already verified) headers and runs the checks configured for it, which
+// have no side effects.
+func (v *Verifier) checkEvent(event *WebhookEvent, raw []byte, headers http.Header) error {
+	// The header has already been checked by parseHeaders.
+	event.RawTimestamp = headers.Get(v.timestampHeader)
+	if n, err := strconv.ParseInt(event.RawTimestamp, 10, 64); err == nil {
+		event.Timestamp = v.timestampUnit.time(n)
+		event.Age = v.clock.Now().Sub(event.Timestamp)
+	}
+
+	if err := v.checkVersion(event, headers.Get(versionHeader)); err != nil {
+		return err
+	}
+	if err := v.checkTimestamp(raw, event.RawTimestamp); err != nil {
+		return err
+	}
+	for _, validate := range v.validators {
+		if err := validate(event, raw); err != nil {
+			if !errors.Is(err, ErrInvalidPayload) {
+				err = fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// allowedEvents returns the events, and their raw JSON, whose type is
+// allowed by WithAllowedEventTypes, counting the others as ignored.
+func (v *Verifier) allowedEvents(events []*WebhookEvent, raws []json.RawMessage) ([]*WebhookEvent, []json.RawMessage) {
+	var keptEvents []*WebhookEvent
+	var keptRaws []json.RawMessage
+	for i, event := range events {
+		if !v.allowedTypes[event.Type] {
+			v.ignore(event.Type)
+			continue
+		}
+		keptEvents = append(keptEvents, event)
+		keptRaws = append(keptRaws, raws[i])
+	}
+	return keptEvents, keptRaws
+}
+
+// checkTimestamp compares the "timestamp" field of an event's raw JSON, if
+// any, with the (already verified) timestamp header, as configured with
+// WithTimestampMatch.
+func (v *Verifier) checkTimestamp(raw []byte, header string) error {
+	if !v.matchTimestamp {
+		return nil
+	}
+	var envelope struct {
+		Timestamp json.RawMessage `json:"timestamp"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil || envelope.Timestamp == nil {
+		return nil
+	}
+	payload, err := v.parsePayloadTimestamp(envelope.Timestamp)
+	if err != nil {
+		return fmt.Errorf("%w: timestamp field: %v", ErrInvalidPayload, err)
+	}
+	n, _ := strconv.ParseInt(header, 10, 64)
+	if diff := payload.Sub(v.timestampUnit.time(n)).Abs(); diff > v.timestampSlack {
+		return fmt.Errorf("%w: payload timestamp is %v away from the header", ErrTimestampMismatch, diff)
+	}
+	return nil
+}
+
+// parsePayloadTimestamp decodes a "timestamp" field, a number in v's
+// timestamp unit or an RFC 3339 string.
+func (v *Verifier) parsePayloadTimestamp(field json.RawMessage) (time.Time, error) {
+	var n int64
+	if err := json.Unmarshal(field, &n); err == nil {
+		return v.timestampUnit.time(n), nil
+	}
+	var s string
+	if err := json.Unmarshal(field, &s); err != nil {
+		return time.Time{}, errors.New("not a number or string")
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// checkVersion fills in event's version from the (already verified) version
+// header and checks it against WithSupportedVersions.
+func (v *Verifier) checkVersion(event *WebhookEvent, header string) error {
+	switch {
+	case event.Version == "":
+		event.Version = header
+	case header != "" && header != event.Version:
+		return fmt.Errorf("%w: version header %q does not match envelope version %q", ErrInvalidEvent, header, event.Version)
+	}
+	if event.Version != "" && !v.supportsVersion(event.Version) {
+		return fmt.Errorf("%w: %q", ErrUnsupportedVersion, event.Version)
+	}
+	return nil
+}
+
+func (v *Verifier) logResult(r *http.Request, events []*WebhookEvent, body []byte, err error) {
+	attrs := []slog.Attr{
+		slog.String("delivery_id", r.Header.Get(deliveryIDHeader)),
+	}
+	if errors.Is(err, ErrEventIgnored) {
+		attrs = append(attrs, slog.String("result", "ignored"))
+		v.logger.LogAttrs(r.Context(), slog.LevelDebug, "Webhook ignored", attrs...)
+		return
+	}
+	if err != nil {
+		if v.logSampler != nil && !v.sampleRejection(r, err) {
+			return
+		}
+		attrs = append(attrs, slog.String("result", "rejected"), slog.String("reason", err.Error()))
+		if v.logBody && body != nil {
+			attrs = append(attrs, slog.String("body", string(body)))
+		}
+		v.logger.LogAttrs(r.Context(), slog.LevelWarn, "Webhook rejected", attrs...)
+		return
+	}
+	if v.quietAccepted {
+		return
+	}
+
+	attrs = append(attrs, slog.String("result", "accepted"))
+	if len(events) == 1 {
+		attrs = append(attrs,
+			slog.String("event", string(events[0].Type)),
+			slog.String("room_id", events[0].RoomID),
+		)
+	} else if events != nil {
+		attrs = append(attrs, slog.Int("events", len(events)))
+	}
+	if v.logBody {
+		attrs = append(attrs, slog.String("body", string(body)))
+	}
+	v.logger.LogAttrs(r.Context(), slog.LevelInfo, "Webhook received", attrs...)
+}
+
+// sampleRejection reports whether the rejection of r with err should be
+// logged under WithRejectionLogSampling, first logging how many rejections
+// for the same reason were left out in the previous window, if any.
+func (v *Verifier) sampleRejection(r *http.Request, err error) bool {
+	reason := Reason(err)
+	log, suppressed, since := v.logSampler.sample(reason, v.clock.Now())
+	if suppressed > 0 {
+		v.logSuppressed(r.Context(), sampleSummary{reason: reason, suppressed: suppressed, since: since})
+	}
+	return log
+}
+
+// readBody reads at most limit bytes from body, failing with ErrBodyTooLarge
+// if there are more.
+func readBody(body io.Reader, limit int64) ([]byte, error) {
+	return readBodySize(body, limit, -1)
+}
+
+// readBodySize is like readBody for a body expected to be size bytes long,
+// or of unknown length if size is negative. The buffer is allocated once
+// for bodies of the expected size.
+func readBodySize(body io.Reader, limit, size int64) ([]byte, error) {
+	var buf bytes.Buffer
+	if size >= 0 && size <= limit {
+		// ReadFrom wants MinRead bytes free before it sees EOF.
+		buf.Grow(int(size) + bytes.MinRead)
+	}
+	_, err := buf.ReadFrom(io.LimitReader(body, limit+1))
+	b := buf.Bytes()
+	if errors.Is(err, ErrInvalidEncoding) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrReadBody, err)
+	}
+	if int64(len(b)) > limit {
+		return nil, ErrBodyTooLarge
+	}
+	return b, nil
+}
+
+// Handler returns a net/http handler that verifies and parses incoming
+// webhooks with v and passes them to next. Rejected requests get a JSON
+// body of the form {"error": "...", "request_id": "..."} with the status
+// given by StatusCode, unless WithErrorResponder is set. The request passed
+// to next carries the correlation id (see AttachRequestID) in its context.
+// With WithResponseCache, 2xx responses written by next are stored, and
+// retries of the delivery are answered with them without calling next.
+// Deliveries answered with any other status are released (see
+// Verifier.Complete), so their retries reach next again.
+func Handler(v *Verifier, next func(http.ResponseWriter, *http.Request, *WebhookEvent)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r = AttachRequestID(r)
+		event, _, err := v.ReadRequest(r)
+		if err != nil {
+			v.writeRejection(w, r, err)
+			return
+		}
+		v.serveDelivery(w, r, func(w http.ResponseWriter) { next(w, r, event) })
+	}
+}
+
+// PassthroughHandler returns a net/http handler that verifies incoming
+// webhooks with v and passes the verified body to next without parsing it,
+// for relays that forward webhooks untouched, for example with a
+// Forwarder. This passthrough mode is distinct from the dispatch mode of
+// Handler, DispatchHandler and BatchHandler, which parse the body into
+// events: the body is read once, verified while it is read and handed to
+// next as is, decompressed if it was sent gzip-encoded, and no events are
+// built. See ReadVerified for the checks that are skipped. Rejected requests
+// and cached responses are handled as by Handler.
+func PassthroughHandler(v *Verifier, next func(w http.ResponseWriter, r *http.Request, body []byte)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r = AttachRequestID(r)
+		body, err := v.ReadVerified(r)
+		if err != nil {
+			v.writeRejection(w, r, err)
+			return
+		}
+		v.serveDelivery(w, r, func(w http.ResponseWriter) { next(w, r, body) })
+	}
+}
+
+// writeRejection answers r, which failed with err, with the cached
+// response if it is a retry of a handled delivery, and with WriteError
+// otherwise.
+func (v *Verifier) writeRejection(w http.ResponseWriter, r *http.Request, err error) {
+	if resp, ok := v.CachedResponse(r, err); ok {
+		resp.Write(w)
+		return
+	}
+	v.WriteError(w, r, err)
+}
+
+// WriteSuccess answers an accepted webhook with the status and body set
+// with WithSuccessStatus and WithSuccessResponse, 200 with an empty body by
+// default. It is used by DispatchHandler and BatchHandler, and is meant
+// for handlers written against Handler or ReadRequest.
+func (v *Verifier) WriteSuccess(w http.ResponseWriter) {
+	if v.successBody == nil {
+		w.WriteHeader(v.successStatus)
+		return
+	}
+	w.Header().Set("Content-Type", v.successType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(v.successBody)))
+	w.WriteHeader(v.successStatus)
+	w.Write(v.successBody)
+}
+
+// ErrorResponder writes the response to a request r that failed with err.
+// status is the status StatusCode returns for err. See WithErrorResponder.
+type ErrorResponder func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+// WriteError answers r, which failed with err, with the responder set with
+// WithErrorResponder, or with a JSON body of the form {"error": "...",
+// "request_id": "..."} and the status given by StatusCode by default. The
+// error is described by ErrorMessage, so handler failures and panics only
+// reach the sender as "Internal Server Error"; their details are logged.
+// Events ignored with WithAllowedEventTypes are answered with
+// WriteSuccess. It is used by the handlers in this module, and is meant for
+// handlers written against Handler or ReadRequest.
+func (v *Verifier) WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrEventIgnored) {
+		v.WriteSuccess(w)
+		return
+	}
+	if v.errorResponder != nil {
+		v.errorResponder(w, r, StatusCode(err), err)
+		return
+	}
+	writeError(w, r, StatusCode(err), err)
+}
+
+// ErrorResponder returns the responder set with WithErrorResponder, or nil
+// if there is none.
+func (v *Verifier) ErrorResponder() ErrorResponder {
+	return v.errorResponder
+}
+
+// ErrorMessage returns what the answer to a request that failed with err,
+// answered with status, tells the sender: err's message for rejected
+// webhooks, and only the status text for 5xx failures, whose messages may
+// hold internal details such as a handler's database error or a panic
+// value.
+func ErrorMessage(status int, err error) string {
+	if status >= 500 {
+		return http.StatusText(status)
+	}
+	return err.Error()
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	resp := map[string]string{"error": ErrorMessage(status, err)}
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		resp["request_id"] = id
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
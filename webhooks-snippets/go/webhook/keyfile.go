@@ -0,0 +1,30 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadKeysFromFile reads API keys from the file at path, such as a
+// Kubernetes secret mounted as a file. Each non-empty line holds one key,
+// with surrounding whitespace trimmed. The first key is meant to be passed
+// to NewVerifier and the rest, if any, to WithAdditionalKeys.
+func LoadKeysFromFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: reading key file: %w", err)
+	}
+
+	var keys []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if key := strings.TrimSpace(line); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("webhook: key file is empty")
+	}
+	return keys, nil
+}
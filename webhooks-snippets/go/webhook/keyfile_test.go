@@ -0,0 +1,38 @@
+package webhook
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadKeysFromFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"secret\n", []string{"secret"}},
+		{"  new-key \r\n\nold-key", []string{"new-key", "old-key"}},
+		{"\n \n", nil},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, "key"+string(rune('a'+i)))
+		if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := LoadKeysFromFile(path)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("LoadKeysFromFile(%q) succeeded, want error", tt.content)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("LoadKeysFromFile(%q) = %q, %v; want %q", tt.content, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// KeyProvider selects the keys a webhook may be signed with from its
+// headers, for receivers shared by several OpenVidu Meet projects, each
+// with its own API key. See WithKeyProvider.
+type KeyProvider interface {
+	// KeyForRequest returns the keys accepted for a webhook sent with
+	// headers, or an error wrapping ErrUnknownTenant if there are none.
+	// With SchemeEd25519 the keys are raw 32-byte public keys.
+	KeyForRequest(headers http.Header) ([][]byte, error)
+}
+
+// TenantKeyProvider is implemented by KeyProviders that can name the tenant
+// a webhook belongs to. The name labels the clock drift reported with
+// DriftMetrics and WithOnDrift. StaticKeyProvider implements it.
+type TenantKeyProvider interface {
+	KeyProvider
+	// Tenant returns the tenant of a webhook sent with headers.
+	Tenant(headers http.Header) string
+}
+
+// StaticKeyProvider is a KeyProvider that selects keys by the value of a
+// request header, such as a tenant or project id, from a fixed map.
+type StaticKeyProvider struct {
+	header string
+	keys   map[string][][]byte
+}
+
+// NewStaticKeyProvider returns a StaticKeyProvider that accepts the keys
+// keys[value] for webhooks whose header named header is value. Each tenant
+// may have several keys, as during a key rotation.
+func NewStaticKeyProvider(header string, keys map[string][]string) (*StaticKeyProvider, error) {
+	if header == "" {
+		return nil, errors.New("webhook: tenant header must not be empty")
+	}
+
+	p := &StaticKeyProvider{header: header, keys: make(map[string][][]byte, len(keys))}
+	for tenant, tenantKeys := range keys {
+		if len(tenantKeys) == 0 {
+			return nil, fmt.Errorf("webhook: no keys for tenant %q", tenant)
+		}
+		for _, key := range tenantKeys {
+			if key == "" {
+				return nil, fmt.Errorf("webhook: empty key for tenant %q", tenant)
+			}
+			p.keys[tenant] = append(p.keys[tenant], []byte(key))
+		}
+	}
+	return p, nil
+}
+
+// KeyForRequest implements KeyProvider.
+func (p *StaticKeyProvider) KeyForRequest(headers http.Header) ([][]byte, error) {
+	tenant := headers.Get(p.header)
+	if tenant == "" {
+		return nil, fmt.Errorf("%w: missing %s header", ErrUnknownTenant, p.header)
+	}
+	keys, ok := p.keys[tenant]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTenant, tenant)
+	}
+	return keys, nil
+}
+
+// Tenant implements TenantKeyProvider. It returns the value of the tenant
+// header.
+func (p *StaticKeyProvider) Tenant(headers http.Header) string {
+	return headers.Get(p.header)
+}
+
+// requestKeys returns the keys a webhook sent with headers may be signed
+// with: the KeyProvider's, if any, or v's own.
+func (v *Verifier) requestKeys(headers http.Header) (*keySet, error) {
+	if v.keyProvider == nil {
+		return v.keys.Load(), nil
+	}
+
+	keys, err := v.keyProvider.KeyForRequest(headers)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrUnknownTenant
+	}
+	return &keySet{keys: keys}, nil
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStaticKeyProvider(t *testing.T) {
+	body := []byte(testBody)
+	provider, err := NewStaticKeyProvider("X-Project-Id", map[string][]string{
+		"project-a": {"key-a"},
+		"project-b": {"key-b", "old-key-b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := mustVerifier(t, "", WithKeyProvider(provider))
+
+	signed := func(project, key string) http.Header {
+		headers := signedHeaders(key, body, time.Now())
+		if project != "" {
+			headers.Set("X-Project-Id", project)
+		}
+		return headers
+	}
+
+	tests := []struct {
+		name      string
+		headers   http.Header
+		wantIndex int
+		want      error
+	}{
+		{"tenant key", signed("project-a", "key-a"), 0, nil},
+		{"old tenant key", signed("project-b", "old-key-b"), 1, nil},
+		{"other tenant's key", signed("project-b", "key-a"), -1, ErrSignatureMismatch},
+		{"unknown tenant", signed("project-c", "key-a"), -1, ErrUnknownTenant},
+		{"missing header", signed("", "key-a"), -1, ErrUnknownTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, err := v.VerifyKey(t.Context(), body, tt.headers)
+			if i != tt.wantIndex || !errors.Is(err, tt.want) {
+				t.Errorf("VerifyKey() = %d, %v, want %d, %v", i, err, tt.wantIndex, tt.want)
+			}
+		})
+	}
+
+	if got := StatusCode(ErrUnknownTenant); got != http.StatusUnauthorized {
+		t.Errorf("StatusCode(ErrUnknownTenant) = %d, want %d", got, http.StatusUnauthorized)
+	}
+	headers := signed("project-a", "key-a")
+	if got := v.ExpectedSignature(headers, body); got != headers.Get("x-signature") {
+		t.Errorf("ExpectedSignature() = %q, want %q", got, headers.Get("x-signature"))
+	}
+}
+
+func TestWithKeyProviderRejectsFixedKeys(t *testing.T) {
+	provider, err := NewStaticKeyProvider("X-Project-Id", map[string][]string{"project-a": {"key-a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewVerifier(testKey, WithKeyProvider(provider)); err == nil {
+		t.Error("NewVerifier() with api key succeeded, want error")
+	}
+	if _, err := NewVerifier("", WithKeyProvider(provider), WithAdditionalKeys("old-key")); err == nil {
+		t.Error("NewVerifier() with additional keys succeeded, want error")
+	}
+	if err := mustVerifier(t, "", WithKeyProvider(provider)).SetKeys([]string{testKey}); err == nil {
+		t.Error("SetKeys() succeeded, want error")
+	}
+}
@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"sync"
+	"time"
+)
+
+// MaxLastSeenSources bounds the number of sources Verifier.LastSeen
+// remembers. When a new source is seen while the table is full, the source
+// that has been silent the longest is forgotten.
+const MaxLastSeenSources = 1024
+
+// lastSeenTable records when each source last sent a verified webhook.
+// The zero value is ready to use.
+type lastSeenTable struct {
+	mu      sync.RWMutex
+	sources map[string]time.Time
+}
+
+func (t *lastSeenTable) record(source string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.sources == nil {
+		t.sources = make(map[string]time.Time)
+	}
+	if _, ok := t.sources[source]; !ok && len(t.sources) >= MaxLastSeenSources {
+		t.evictOldest()
+	}
+	if at.After(t.sources[source]) {
+		t.sources[source] = at
+	}
+}
+
+func (t *lastSeenTable) evictOldest() {
+	var oldest string
+	var oldestAt time.Time
+	for source, at := range t.sources {
+		if oldestAt.IsZero() || at.Before(oldestAt) {
+			oldest, oldestAt = source, at
+		}
+	}
+	delete(t.sources, oldest)
+}
+
+func (t *lastSeenTable) get(source string) (time.Time, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	at, ok := t.sources[source]
+	return at, ok
+}
+
+// LastSeen returns when v last verified a webhook from source, and whether
+// it has verified any. source is the tenant named by the KeyProvider if it
+// implements TenantKeyProvider, and "" otherwise. Only webhooks that pass
+// signature and replay checks count, so forged requests cannot make a
+// silent source look alive. Combined with a periodic check, or with the
+// gauge kept by metrics implementing LastSeenMetrics, it can back an alert
+// on sources that stopped sending webhooks. At most MaxLastSeenSources
+// sources are remembered.
+func (v *Verifier) LastSeen(source string) (time.Time, bool) {
+	return v.lastSeen.get(source)
+}
@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLastSeen(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	body := []byte(testBody)
+	provider, err := NewStaticKeyProvider("X-Project-Id", map[string][]string{"project-a": {testKey}, "project-b": {"key-b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := mustVerifier(t, "", WithClock(fakeClock{now: now}), WithKeyProvider(provider))
+
+	headers := signedHeaders(testKey, body, now)
+	headers.Set("X-Project-Id", "project-a")
+	if err := v.Verify(body, headers); err != nil {
+		t.Fatal(err)
+	}
+	// A forged webhook does not count as a sign of life.
+	headers = signedHeaders("forged-key", body, now)
+	headers.Set("X-Project-Id", "project-b")
+	v.Verify(body, headers)
+
+	if at, ok := v.LastSeen("project-a"); !ok || !at.Equal(now) {
+		t.Errorf(`LastSeen("project-a") = %v, %v, want %v, true`, at, ok, now)
+	}
+	if at, ok := v.LastSeen("project-b"); ok {
+		t.Errorf(`LastSeen("project-b") = %v, true, want false`, at)
+	}
+}
+
+func TestLastSeenTableBounded(t *testing.T) {
+	var table lastSeenTable
+	start := time.UnixMilli(1700000000000)
+	for i := range MaxLastSeenSources + 1 {
+		table.record(fmt.Sprint("source-", i), start.Add(time.Duration(i)*time.Second))
+	}
+
+	if len(table.sources) != MaxLastSeenSources {
+		t.Errorf("remembered %d sources, want %d", len(table.sources), MaxLastSeenSources)
+	}
+	// The source that has been silent the longest is forgotten.
+	if _, ok := table.get("source-0"); ok {
+		t.Error("oldest source was not evicted")
+	}
+	if _, ok := table.get(fmt.Sprint("source-", MaxLastSeenSources)); !ok {
+		t.Error("newest source was not recorded")
+	}
+}
@@ -0,0 +1,151 @@
+package webhook
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// rejectionSampler limits how many rejections of each reason are logged,
+// or stored, per window. See WithRejectionLogSampling and
+// WithRejectionSinkSampling.
+type rejectionSampler struct {
+	limit  int
+	window time.Duration
+
+	mu      sync.Mutex
+	reasons map[string]*sampleWindow
+}
+
+type sampleWindow struct {
+	start      time.Time
+	logged     int
+	suppressed int
+}
+
+func newRejectionSampler(limit int, window time.Duration) *rejectionSampler {
+	return &rejectionSampler{limit: limit, window: window, reasons: make(map[string]*sampleWindow)}
+}
+
+// sample reports whether a rejection for reason at now should be logged.
+// When it is the first of a new window, it also returns how many
+// rejections for reason were suppressed in the previous one, and when.
+func (s *rejectionSampler) sample(reason string, now time.Time) (log bool, suppressed int, since time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, ok := s.reasons[reason]
+	if !ok {
+		w = &sampleWindow{start: now}
+		s.reasons[reason] = w
+	} else if now.Sub(w.start) >= s.window {
+		suppressed, since = w.suppressed, w.start
+		*w = sampleWindow{start: now}
+	}
+	if w.logged >= s.limit {
+		w.suppressed++
+		return false, suppressed, since
+	}
+	w.logged++
+	return true, suppressed, since
+}
+
+// sampleSummary reports how many rejections for reason a rejectionSampler
+// left out in the window that started at since.
+type sampleSummary struct {
+	reason     string
+	suppressed int
+	since      time.Time
+}
+
+// flush ends the windows that are over at now, or all of them if all is
+// set, and returns a summary of each in which rejections were left out.
+func (s *rejectionSampler) flush(now time.Time, all bool) []sampleSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var summaries []sampleSummary
+	for reason, w := range s.reasons {
+		if !all && now.Sub(w.start) < s.window {
+			continue
+		}
+		if w.suppressed > 0 {
+			summaries = append(summaries, sampleSummary{reason: reason, suppressed: w.suppressed, since: w.start})
+		}
+		delete(s.reasons, reason)
+	}
+	return summaries
+}
+
+// startSampling starts the background summaries of v's samplers, if any.
+func (v *Verifier) startSampling() {
+	var interval time.Duration
+	for _, s := range []*rejectionSampler{v.logSampler, v.sinkSampler} {
+		if s != nil && (interval == 0 || s.window < interval) {
+			interval = s.window
+		}
+	}
+	if interval == 0 {
+		return
+	}
+	v.stop = make(chan struct{})
+	v.stopped = make(chan struct{})
+	go v.summarizeSamples(interval)
+}
+
+// summarizeSamples reports, every interval until Close, the rejections the
+// samplers left out in windows that have ended, so that a burst followed
+// by a quiet period is still reported without waiting for the next
+// rejection. On Close it reports those of the current windows too.
+func (v *Verifier) summarizeSamples(interval time.Duration) {
+	defer close(v.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			v.flushSamples(false)
+		case <-v.stop:
+			v.flushSamples(true)
+			return
+		}
+	}
+}
+
+func (v *Verifier) flushSamples(all bool) {
+	ctx, now := context.Background(), v.clock.Now()
+	if v.logSampler != nil {
+		for _, s := range v.logSampler.flush(now, all) {
+			v.logSuppressed(ctx, s)
+		}
+	}
+	if v.sinkSampler != nil {
+		for _, s := range v.sinkSampler.flush(now, all) {
+			v.logSkipped(ctx, s)
+		}
+	}
+}
+
+// logSuppressed logs how many rejections were left out of the log by
+// WithRejectionLogSampling.
+func (v *Verifier) logSuppressed(ctx context.Context, s sampleSummary) {
+	v.logger.LogAttrs(ctx, slog.LevelWarn, "Suppressed rejected webhook logs",
+		slog.String("reason", s.reason),
+		slog.Int("suppressed", s.suppressed),
+		slog.Time("since", s.since),
+		slog.Duration("window", v.logSampler.window),
+	)
+}
+
+// logSkipped logs how many rejections were not stored in the rejection
+// sink under WithRejectionSinkSampling.
+func (v *Verifier) logSkipped(ctx context.Context, s sampleSummary) {
+	v.logger.LogAttrs(ctx, slog.LevelWarn, "Skipped storing rejected webhooks",
+		slog.String("reason", s.reason),
+		slog.Int("skipped", s.suppressed),
+		slog.Time("since", s.since),
+		slog.Duration("window", v.sinkSampler.window),
+	)
+}
@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRejectionSampler(t *testing.T) {
+	s := newRejectionSampler(2, time.Minute)
+	start := time.UnixMilli(1700000000000)
+
+	var logged int
+	for range 5 {
+		if log, _, _ := s.sample("signature_mismatch", start); log {
+			logged++
+		}
+	}
+	if logged != 2 {
+		t.Errorf("logged %d of 5 in one window, want 2", logged)
+	}
+
+	log, suppressed, since := s.sample("signature_mismatch", start.Add(time.Minute))
+	if !log || suppressed != 3 || !since.Equal(start) {
+		t.Errorf("next window: sample() = %v, %d, %v, want true, 3, %v", log, suppressed, since, start)
+	}
+}
+
+func TestRejectionSamplerFlush(t *testing.T) {
+	s := newRejectionSampler(1, time.Minute)
+	start := time.UnixMilli(1700000000000)
+	for range 3 {
+		s.sample("signature_mismatch", start)
+	}
+	s.sample("missing_signature", start.Add(30*time.Second))
+
+	if got := s.flush(start.Add(time.Minute), false); len(got) != 1 || got[0].reason != "signature_mismatch" || got[0].suppressed != 2 {
+		t.Errorf("flush() of ended windows = %+v, want 2 signature_mismatch", got)
+	}
+	if got := s.flush(start.Add(time.Minute), false); len(got) != 0 {
+		t.Errorf("second flush() = %+v, want nothing", got)
+	}
+	// missing_signature had nothing left out.
+	if got := s.flush(start.Add(time.Minute), true); len(got) != 0 {
+		t.Errorf("flush() of all windows = %+v, want nothing", got)
+	}
+}
+
+// syncBuffer is a bytes.Buffer that can be written from the background
+// summaries while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// suppressedLogs returns the suppressed counts logged in logs, by reason.
+func suppressedLogs(t *testing.T, logs string) map[string]int {
+	t.Helper()
+
+	counts := make(map[string]int)
+	for line := range strings.Lines(logs) {
+		var entry struct {
+			Msg        string `json:"msg"`
+			Reason     string `json:"reason"`
+			Suppressed int    `json:"suppressed"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Msg == "Suppressed rejected webhook logs" {
+			counts[entry.Reason] += entry.Suppressed
+		}
+	}
+	return counts
+}
+
+func TestRejectionLogSamplingSummarizesQuietPeriod(t *testing.T) {
+	body := []byte(testBody)
+	var buf syncBuffer
+	v := mustVerifier(t, testKey,
+		WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))),
+		WithRejectionLogSampling(1, 10*time.Millisecond),
+	)
+	defer v.Close()
+
+	for range 4 {
+		v.ReadRequest(newRequest(body, signedHeaders("other-key", body, time.Now())))
+	}
+
+	// No rejection follows, so only the background summary can report the
+	// three left out.
+	deadline := time.Now().Add(5 * time.Second)
+	for suppressedLogs(t, buf.String())["signature_mismatch"] != 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("no summary logged after a quiet period:\n%s", buf.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestVerifierCloseFlushesSampling(t *testing.T) {
+	body := []byte(testBody)
+	var buf syncBuffer
+	v := mustVerifier(t, testKey,
+		WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))),
+		WithRejectionLogSampling(1, time.Hour),
+	)
+
+	for range 3 {
+		v.ReadRequest(newRequest(body, signedHeaders("other-key", body, time.Now())))
+	}
+	v.Close()
+	v.Close()
+
+	if got := suppressedLogs(t, buf.String()); got["signature_mismatch"] != 2 {
+		t.Errorf("suppressed counts after Close = %v, want 2 signature mismatches", got)
+	}
+}
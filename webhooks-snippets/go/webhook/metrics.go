@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"errors"
+	"time"
+)
+
+// Metrics receives measurements from Verifier.ReadRequest and the HTTP
+// adapters. Implementations must be safe for concurrent use. See the
+// promwebhook package for a Prometheus implementation.
+type Metrics interface {
+	// IncReceived counts a webhook request, whatever its outcome.
+	IncReceived()
+	// IncRejected counts a rejected webhook request. reason is one of the
+	// values returned by Reason.
+	IncRejected(reason string)
+	// ObserveLatency records how long reading, verifying and parsing a
+	// webhook request took.
+	ObserveLatency(d time.Duration)
+}
+
+// VerificationMetrics is implemented by Metrics that also break down the
+// cost of verification by event type. Verifier.ReadRequest detects it when
+// the metrics are set with WithMetrics. promwebhook.Metrics implements it.
+type VerificationMetrics interface {
+	// ObserveVerification records how long computing the signature over
+	// and parsing a body of size bytes took, excluding connection setup
+	// before the body is read, storage and handlers. eventType is "batch"
+	// for batches of several events, "unknown" for bodies that could not
+	// be parsed and "passthrough" for bodies read with
+	// Verifier.ReadVerified, which are not parsed.
+	ObserveVerification(eventType string, d time.Duration, size int)
+}
+
+// DriftMetrics is implemented by Metrics that also record the clock drift
+// observed on verified webhooks. Verifier detects it when the metrics are
+// set with WithMetrics. promwebhook.Metrics implements it.
+type DriftMetrics interface {
+	// ObserveDrift records drift, as passed to a DriftFunc, for a webhook
+	// of tenant.
+	ObserveDrift(tenant string, drift time.Duration)
+}
+
+// LastSeenMetrics is implemented by Metrics that also keep the time each
+// tenant last sent a verified webhook, as reported by Verifier.LastSeen.
+// Verifier detects it when the metrics are set with WithMetrics.
+// promwebhook.Metrics implements it.
+type LastSeenMetrics interface {
+	// SetLastSeen records that a webhook of tenant was verified at t.
+	SetLastSeen(tenant string, t time.Time)
+}
+
+// IgnoredMetrics is implemented by Metrics that also count verified
+// webhooks ignored because of WithAllowedEventTypes. Verifier detects it
+// when the metrics are set with WithMetrics. promwebhook.Metrics
+// implements it.
+type IgnoredMetrics interface {
+	// IncIgnored counts an ignored event of type eventType.
+	IncIgnored(eventType string)
+}
+
+// DriftFunc is called by WithOnDrift for every verified webhook. drift is
+// the local time minus the webhook's timestamp: positive for timestamps in
+// the past, which includes the delivery latency, and negative for
+// timestamps ahead of the local clock. tenant names the webhook's tenant
+// if the KeyProvider implements TenantKeyProvider, and is empty otherwise.
+type DriftFunc func(tenant string, drift time.Duration)
+
+type noopMetrics struct{}
+
+func (noopMetrics) IncReceived()                 {}
+func (noopMetrics) IncRejected(string)           {}
+func (noopMetrics) ObserveLatency(time.Duration) {}
+
+var reasons = []struct {
+	err    error
+	reason string
+}{
+	{ErrMissingSignature, "missing_signature"},
+	{ErrMissingTimestamp, "missing_timestamp"},
+	{ErrInvalidTimestamp, "invalid_timestamp"},
+	{ErrTimestampExpired, "timestamp_expired"},
+	{ErrTimestampInFuture, "timestamp_in_future"},
+	{ErrTimestampMismatch, "timestamp_mismatch"},
+	{ErrMalformedSignature, "malformed_signature"},
+	{ErrUnsupportedAlgorithm, "unsupported_algorithm"},
+	{ErrUnsupportedVersion, "unsupported_version"},
+	{ErrSignatureMismatch, "signature_mismatch"},
+	{ErrUnknownTenant, "unknown_tenant"},
+	{ErrReplayDetected, "replay_detected"},
+	{ErrDuplicateEvent, "duplicate_event"},
+	{ErrInvalidPayload, "invalid_payload"},
+	{ErrInvalidEvent, "invalid_event"},
+	{ErrReadBody, "read_body"},
+	{ErrEmptyBody, "empty_body"},
+	{ErrInvalidEncoding, "invalid_encoding"},
+	{ErrBodyTooLarge, "body_too_large"},
+	{ErrUnsupportedMediaType, "unsupported_media_type"},
+	{ErrRateLimited, "rate_limited"},
+	{ErrForbiddenAddress, "forbidden_address"},
+	{ErrSinkFailed, "sink_failed"},
+	{ErrEventIgnored, "ignored"},
+}
+
+// Reason returns a short, stable label for a verification error, suitable
+// for metric labels: for example "timestamp_expired" for
+// ErrTimestampExpired. Errors that match no sentinel are reported as
+// "unknown".
+func Reason(err error) string {
+	for _, r := range reasons {
+		if errors.Is(err, r.err) {
+			return r.reason
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,758 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// WithMaxAge sets how old a webhook timestamp may be before the event is
+// rejected with ErrTimestampExpired. The default is DefaultMaxAge.
+func WithMaxAge(d time.Duration) Option {
+	return func(v *Verifier) error {
+		if d <= 0 {
+			return errors.New("webhook: max age must be positive")
+		}
+		v.maxAge = d
+		return nil
+	}
+}
+
+// WithClockSkew sets how far ahead of the local clock a webhook timestamp may
+// be before the event is rejected with ErrTimestampInFuture. The default is
+// DefaultClockSkew.
+func WithClockSkew(d time.Duration) Option {
+	return func(v *Verifier) error {
+		if d < 0 {
+			return errors.New("webhook: clock skew must not be negative")
+		}
+		v.clockSkew = d
+		return nil
+	}
+}
+
+// WithSignatureEncoding sets how the signature header is decoded before it
+// is compared. The default is EncodingHex.
+func WithSignatureEncoding(enc Encoding) Option {
+	return func(v *Verifier) error {
+		switch enc {
+		case EncodingHex, EncodingBase64:
+			v.encoding = enc
+			return nil
+		default:
+			return fmt.Errorf("webhook: unsupported signature encoding %v", enc)
+		}
+	}
+}
+
+// WithTimestampUnit sets the unit of the timestamp header, which is used
+// both for the age checks and as part of the signed payload. The default is
+// UnitMilliseconds, which is what OpenVidu Meet sends; use UnitSeconds only
+// when a gateway in between rewrites timestamps to Unix seconds. With
+// UnitSeconds, the max age and clock skew are truncated to whole seconds.
+func WithTimestampUnit(unit TimestampUnit) Option {
+	return func(v *Verifier) error {
+		switch unit {
+		case UnitMilliseconds, UnitSeconds:
+			v.timestampUnit = unit
+			return nil
+		default:
+			return fmt.Errorf("webhook: unsupported timestamp unit %v", unit)
+		}
+	}
+}
+
+// WithAdditionalKeys adds keys that are accepted alongside the primary key.
+// This allows rotating the API key without rejecting webhooks signed with
+// the previous one: deploy with the new key as primary and the old key as
+// additional, then drop the old key once the sender has switched over.
+// Only the primary key is accepted by default.
+func WithAdditionalKeys(keys ...string) Option {
+	return func(v *Verifier) error {
+		v.additionalKeys = append(v.additionalKeys, keys...)
+		return nil
+	}
+}
+
+// WithKeyDeriver makes v use derive(key) as the HMAC key instead of each
+// key passed to NewVerifier and WithAdditionalKeys, for example to apply
+// the same HKDF step the sender uses to derive per-environment keys from a
+// base secret. Keys are derived once, when they are set by NewVerifier or
+// Verifier.SetKeys, and only the derived keys are kept; they are used both
+// to verify and by Verifier.Sign. By default keys are used as given.
+func WithKeyDeriver(derive func(base []byte) []byte) Option {
+	return func(v *Verifier) error {
+		if derive == nil {
+			return errors.New("webhook: key deriver must not be nil")
+		}
+		v.deriveKey = derive
+		return nil
+	}
+}
+
+// WithReplayCache rejects deliveries already accepted within the webhook age
+// window with ErrReplayDetected. Deliveries are identified by their
+// timestamp and verified signature: the HMAC with the first key, whichever
+// of the signatures sent matched and however they were encoded, or the
+// signed message with SchemeEd25519. Headers that are not signed, such as
+// "x-delivery-id", are not used, since a replay could change them. Only
+// stores that implement ReplayReserver reject concurrent copies of a
+// delivery reliably. Replay protection is disabled by default.
+func WithReplayCache(store ReplayStore) Option {
+	return func(v *Verifier) error {
+		if store == nil {
+			return errors.New("webhook: replay store must not be nil")
+		}
+		v.replay = store
+		return nil
+	}
+}
+
+// WithDedupeByPayload rejects webhooks whose body is identical to one
+// accepted within ttl with ErrDuplicateEvent, for senders that retry with
+// a new timestamp, which the replay check sees as a new delivery. Bodies
+// are identified by their SHA-256, recorded in store only after the
+// signature has been verified. store may be the same one passed to
+// WithReplayCache. Deduplication is disabled by default.
+func WithDedupeByPayload(store ReplayStore, ttl time.Duration) Option {
+	return func(v *Verifier) error {
+		if store == nil {
+			return errors.New("webhook: dedupe store must not be nil")
+		}
+		if ttl <= 0 {
+			return errors.New("webhook: dedupe ttl must be positive")
+		}
+		v.dedupe = store
+		v.dedupeTTL = ttl
+		return nil
+	}
+}
+
+// WithSignatureHeader sets the header the signature is read from. The
+// default is DefaultSignatureHeader.
+func WithSignatureHeader(name string) Option {
+	return func(v *Verifier) error {
+		if name == "" {
+			return errors.New("webhook: signature header must not be empty")
+		}
+		v.signatureHeader = name
+		return nil
+	}
+}
+
+// WithTimestampHeader sets the header the timestamp is read from. The
+// default is DefaultTimestampHeader.
+func WithTimestampHeader(name string) Option {
+	return func(v *Verifier) error {
+		if name == "" {
+			return errors.New("webhook: timestamp header must not be empty")
+		}
+		v.timestampHeader = name
+		return nil
+	}
+}
+
+// WithHashAlgorithm sets the hash function used for the HMAC signature. The
+// default is HashSHA256, which is what OpenVidu Meet uses.
+func WithHashAlgorithm(alg HashAlgorithm) Option {
+	return func(v *Verifier) error {
+		switch alg {
+		case HashSHA256, HashSHA512:
+			v.hash = alg
+			return nil
+		default:
+			return fmt.Errorf("webhook: unsupported hash algorithm %v", alg)
+		}
+	}
+}
+
+// WithSignatureScheme sets how signatures are checked. The default is
+// SchemeHMAC, which is what OpenVidu Meet uses. With SchemeEd25519, the API
+// key passed to NewVerifier and any additional keys are the senders'
+// public keys; see SchemeEd25519 for their format.
+func WithSignatureScheme(s SignatureScheme) Option {
+	return func(v *Verifier) error {
+		switch s {
+		case SchemeHMAC, SchemeEd25519:
+			v.scheme = s
+			return nil
+		default:
+			return fmt.Errorf("webhook: unsupported signature scheme %v", s)
+		}
+	}
+}
+
+// WithMaxBodySize sets the largest webhook body, in bytes, that
+// Verifier.ReadRequest and the HTTP adapters will read. Larger bodies are
+// rejected with ErrBodyTooLarge. The default is DefaultMaxBodySize.
+func WithMaxBodySize(n int64) Option {
+	return func(v *Verifier) error {
+		if n <= 0 {
+			return errors.New("webhook: max body size must be positive")
+		}
+		v.maxBodySize = n
+		return nil
+	}
+}
+
+// WithRequireContentType rejects requests whose Content-Type media type is
+// not contentType with ErrUnsupportedMediaType, before the body is read.
+// Parameters such as charset are ignored. OpenVidu Meet sends
+// "application/json". No check is done by default.
+func WithRequireContentType(contentType string) Option {
+	return func(v *Verifier) error {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return fmt.Errorf("webhook: invalid content type %q: %w", contentType, err)
+		}
+		v.contentType = mediaType
+		return nil
+	}
+}
+
+// WithLogger sets the logger used to report accepted and rejected webhooks
+// read by Verifier.ReadRequest and the HTTP adapters. Nothing is logged by
+// default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(v *Verifier) error {
+		if logger == nil {
+			return errors.New("webhook: logger must not be nil")
+		}
+		v.logger = withRequestIDs(logger)
+		return nil
+	}
+}
+
+// WithLogBody includes the raw webhook body in log entries. Bodies may
+// contain sensitive data, so this is meant for debugging only and is off by
+// default.
+func WithLogBody(enabled bool) Option {
+	return func(v *Verifier) error {
+		v.logBody = enabled
+		return nil
+	}
+}
+
+// WithLogAccepted sets whether accepted webhooks are logged, independently
+// of rejected ones. With a busy sender, turning it off keeps the log for
+// rejections. Accepted webhooks are logged by default.
+func WithLogAccepted(enabled bool) Option {
+	return func(v *Verifier) error {
+		v.quietAccepted = !enabled
+		return nil
+	}
+}
+
+// WithRejectionLogSampling logs at most n rejected webhooks per reason (as
+// returned by Reason) in each window, so a misconfigured sender does not
+// flood the log with identical lines. How many were left out in a window
+// is logged along with the reason once the window ends, even if no
+// rejections follow, and on Verifier.Close. Metrics and the rejection sink
+// still see every rejection. All rejections are logged by default.
+func WithRejectionLogSampling(n int, window time.Duration) Option {
+	return func(v *Verifier) error {
+		if n <= 0 {
+			return errors.New("webhook: rejection log sample size must be positive")
+		}
+		if window <= 0 {
+			return errors.New("webhook: rejection log sampling window must be positive")
+		}
+		v.logSampler = newRejectionSampler(n, window)
+		return nil
+	}
+}
+
+// WithMetrics reports webhook request counts, rejection reasons and latency
+// to m, as well as verification cost if m implements VerificationMetrics
+// and clock drift if it implements DriftMetrics. No metrics are collected
+// by default.
+func WithMetrics(m Metrics) Option {
+	return func(v *Verifier) error {
+		if m == nil {
+			return errors.New("webhook: metrics must not be nil")
+		}
+		v.metrics = m
+		v.verifyMetrics, _ = m.(VerificationMetrics)
+		v.driftMetrics, _ = m.(DriftMetrics)
+		v.lastSeenMetrics, _ = m.(LastSeenMetrics)
+		v.ignoredMetrics, _ = m.(IgnoredMetrics)
+		return nil
+	}
+}
+
+// WithOnDrift calls fn with the clock drift between the sender and v, as
+// measured by the age check, for every webhook that passes verification,
+// so drift can be alerted on before it grows into rejections. It only
+// observes: the age limits are still set by WithMaxAge and WithClockSkew.
+// fn is called synchronously during verification and must not block.
+// There is no drift callback by default.
+func WithOnDrift(fn DriftFunc) Option {
+	return func(v *Verifier) error {
+		if fn == nil {
+			return errors.New("webhook: drift callback must not be nil")
+		}
+		v.onDrift = fn
+		return nil
+	}
+}
+
+// WithRateLimit limits all webhook requests to rps per second with bursts of
+// up to burst requests. Requests over the limit are rejected with
+// ErrRateLimited before their body is read. There is no limit by default.
+func WithRateLimit(rps float64, burst int) Option {
+	return func(v *Verifier) error {
+		if rps <= 0 || burst <= 0 {
+			return errors.New("webhook: rate limit and burst must be positive")
+		}
+		v.rateLimiter().global = rate.NewLimiter(rate.Limit(rps), burst)
+		return nil
+	}
+}
+
+// WithPerIPRateLimit is like WithRateLimit but keeps a separate bucket for
+// each remote IP address, or each /64 for IPv6, for up to
+// MaxRateLimitedIPs addresses. It can be combined with WithRateLimit, in
+// which case a request must pass both limits. Behind a reverse proxy, set
+// WithTrustedProxies so clients are told apart. There is no per-IP limit
+// by default.
+func WithPerIPRateLimit(rps float64, burst int) Option {
+	return func(v *Verifier) error {
+		if rps <= 0 || burst <= 0 {
+			return errors.New("webhook: rate limit and burst must be positive")
+		}
+		l := v.rateLimiter()
+		l.perIP = true
+		l.ipRate = rate.Limit(rps)
+		l.ipBurst = burst
+		return nil
+	}
+}
+
+func (v *Verifier) rateLimiter() *rateLimiter {
+	if v.limiter == nil {
+		v.limiter = &rateLimiter{}
+	}
+	return v.limiter
+}
+
+// WithHandlerTimeout bounds how long Verifier.RunHandler and
+// DispatchHandler wait for a handler. When the timeout expires the
+// handler's context is canceled and ErrHandlerTimeout is returned, which
+// answers the webhook with 503. There is no timeout by default.
+func WithHandlerTimeout(d time.Duration) Option {
+	return func(v *Verifier) error {
+		if d <= 0 {
+			return errors.New("webhook: handler timeout must be positive")
+		}
+		v.handlerTimeout = d
+		return nil
+	}
+}
+
+// WithClock sets the clock used for the webhook age checks, rate limiting
+// and latency metrics. The default is the system clock.
+func WithClock(c Clock) Option {
+	return func(v *Verifier) error {
+		if c == nil {
+			return errors.New("webhook: clock must not be nil")
+		}
+		v.clock = c
+		return nil
+	}
+}
+
+// WithAllowedCIDRs rejects requests from client addresses outside the given
+// CIDR ranges with ErrForbiddenAddress (403) before the body is read. The
+// client address is taken from the connection, or from X-Forwarded-For when
+// WithTrustedProxies is set. All addresses are allowed by default.
+func WithAllowedCIDRs(cidrs []string) Option {
+	return func(v *Verifier) error {
+		if len(cidrs) == 0 {
+			return errors.New("webhook: allowed CIDR list must not be empty")
+		}
+		nets := make([]netip.Prefix, 0, len(cidrs))
+		for _, cidr := range cidrs {
+			prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+			if err != nil {
+				return fmt.Errorf("webhook: invalid CIDR %q: %w", cidr, err)
+			}
+			nets = append(nets, prefix.Masked())
+		}
+		v.allowedNets = nets
+		return nil
+	}
+}
+
+// WithTrustedProxies sets how many reverse proxies sit in front of the
+// server. With n proxies the client address is the n-th entry from the
+// right of the X-Forwarded-For header; entries further left were supplied
+// by the client and are ignored. It affects WithAllowedCIDRs and
+// WithPerIPRateLimit. The default is 0, which uses the connection's remote
+// address and ignores X-Forwarded-For.
+func WithTrustedProxies(n int) Option {
+	return func(v *Verifier) error {
+		if n < 0 {
+			return errors.New("webhook: trusted proxy count must not be negative")
+		}
+		v.trustedProxies = n
+		return nil
+	}
+}
+
+// WithEventSink stores every verified and parsed event in sink before
+// Verifier.ReadRequest returns it. What happens when storing fails is set
+// with WithSinkFailureMode. No sink is used by default.
+func WithEventSink(sink EventSink) Option {
+	return func(v *Verifier) error {
+		if sink == nil {
+			return errors.New("webhook: event sink must not be nil")
+		}
+		v.sink = sink
+		return nil
+	}
+}
+
+// WithRejectionSink records every webhook rejected by ReadRequest and
+// ReadBatch in sink, whether it failed verification or parsing, along with
+// the reason, so rejections can be reviewed later. Only the start of the
+// body is kept (see RejectionBodyLimit), credentials are removed from the
+// headers and the signature is redacted unless WithRejectionSignatures is
+// set. The API key is never part of a request, so it is never stored.
+// Headers are bounded too, see RejectionHeaderLimit. Requests over the rate
+// limit are not recorded, and neither are rejections beyond the budget set
+// with WithRejectionSinkSampling. A failure to store a rejection is
+// logged. Rejections are not recorded by default.
+func WithRejectionSink(sink RejectionSink) Option {
+	return func(v *Verifier) error {
+		if sink == nil {
+			return errors.New("webhook: rejection sink must not be nil")
+		}
+		v.rejectionSink = sink
+		if v.sinkSampler == nil {
+			v.sinkSampler = newRejectionSampler(DefaultRejectionSinkLimit, DefaultRejectionSinkWindow)
+		}
+		return nil
+	}
+}
+
+// WithRejectionSinkSampling stores at most n rejected webhooks per reason
+// (as returned by Reason) in each window in the rejection sink, so a flood
+// of forged requests cannot fill it. How many were left out in a window is
+// logged once it ends, and on Verifier.Close. The default is
+// DefaultRejectionSinkLimit per DefaultRejectionSinkWindow.
+func WithRejectionSinkSampling(n int, window time.Duration) Option {
+	return func(v *Verifier) error {
+		if n <= 0 {
+			return errors.New("webhook: rejection sink sample size must be positive")
+		}
+		if window <= 0 {
+			return errors.New("webhook: rejection sink sampling window must be positive")
+		}
+		v.sinkSampler = newRejectionSampler(n, window)
+		return nil
+	}
+}
+
+// WithRejectionSignatures keeps the signature header of rejected webhooks
+// passed to the rejection sink, which helps telling a wrong key from a
+// tampered body. Signatures are redacted by default.
+func WithRejectionSignatures(enabled bool) Option {
+	return func(v *Verifier) error {
+		v.keepSignatures = enabled
+		return nil
+	}
+}
+
+// WithSinkFailureMode sets what happens to a webhook the event sink fails
+// to store. The default is SinkFailureLog, which accepts it anyway;
+// SinkFailureReject answers 503 so the sender retries.
+func WithSinkFailureMode(mode SinkFailureMode) Option {
+	return func(v *Verifier) error {
+		switch mode {
+		case SinkFailureLog, SinkFailureReject:
+			v.sinkFailure = mode
+			return nil
+		default:
+			return fmt.Errorf("webhook: unsupported sink failure mode %v", mode)
+		}
+	}
+}
+
+// EventValidator checks a parsed event, along with the body it was parsed
+// from, beyond what ParseEvent requires. Errors that do not already match
+// ErrInvalidPayload are wrapped with it.
+type EventValidator func(event *WebhookEvent, body []byte) error
+
+// WithEventValidator makes Verifier.ReadRequest reject events that fail
+// validate, after they have been verified and parsed. It may be given
+// several times; validators run in order. See the schemawebhook package
+// for a JSON Schema validator. No validators run by default.
+func WithEventValidator(validate EventValidator) Option {
+	return func(v *Verifier) error {
+		if validate == nil {
+			return errors.New("webhook: event validator must not be nil")
+		}
+		v.validators = append(v.validators, validate)
+		return nil
+	}
+}
+
+// WithGzipSigning sets whether the signature of a gzip-compressed webhook
+// is checked against the decompressed body or the compressed bytes as
+// received. The default is SignedDecompressed, matching OpenVidu Meet,
+// which signs the body before it is compressed on the way.
+func WithGzipSigning(s GzipSigning) Option {
+	return func(v *Verifier) error {
+		switch s {
+		case SignedDecompressed, SignedCompressed:
+			v.gzipSigning = s
+			return nil
+		default:
+			return fmt.Errorf("webhook: unsupported gzip signing %v", s)
+		}
+	}
+}
+
+// WithSupportedVersions rejects webhooks whose format version, sent in the
+// "x-webhook-version" header or the envelope's "version" field, is not one
+// of versions with ErrUnsupportedVersion. The header is covered by the
+// signature (see SignVersion), as is the envelope. Unversioned webhooks,
+// such as those sent by current OpenVidu Meet releases, are still
+// accepted. Any version is accepted by default.
+func WithSupportedVersions(versions ...string) Option {
+	return func(v *Verifier) error {
+		if len(versions) == 0 {
+			return errors.New("webhook: supported versions must not be empty")
+		}
+		for _, version := range versions {
+			if version == "" {
+				return errors.New("webhook: supported version must not be empty")
+			}
+		}
+		v.versions = slices.Clone(versions)
+		return nil
+	}
+}
+
+// WithOrderingCheck calls report, synchronously from ReadRequest and
+// ReadBatch, for each accepted event that arrived out of order for its
+// room. Events that carry a sequence number are checked against the
+// previous one, so both reordered events and gaps are reported; for other
+// events, ordering is derived from their creation date, which only reveals
+// reordering. Events are never rejected: report decides what to do, such
+// as buffering the event or resynchronizing the room's state. Events
+// without a room id are not checked. There is no ordering check by
+// default.
+//
+// The newest sequence number and creation date are kept in memory for
+// every room seen, a few dozen bytes per room, until an EventRoomClosed is
+// received for it. Rooms that are never closed, or whose closing event is
+// lost, are tracked until the process exits, and the tracking is per
+// Verifier, so instances behind a load balancer each see only part of the
+// sequence.
+func WithOrderingCheck(report OrderingFunc) Option {
+	return func(v *Verifier) error {
+		if report == nil {
+			return errors.New("webhook: ordering callback must not be nil")
+		}
+		v.ordering = &orderTracker{report: report, rooms: make(map[string]roomOrder)}
+		return nil
+	}
+}
+
+// WithKeyProvider makes v choose the keys each webhook may be signed with
+// from its headers with p, instead of using fixed keys, so one receiver can
+// verify webhooks from several OpenVidu Meet projects. Webhooks for which p
+// finds no keys are rejected with ErrUnknownTenant. The api key passed to
+// NewVerifier must then be empty, and WithAdditionalKeys, WithKeyDeriver
+// and SetKeys do not apply. Fixed keys are used by default.
+func WithKeyProvider(p KeyProvider) Option {
+	return func(v *Verifier) error {
+		if p == nil {
+			return errors.New("webhook: key provider must not be nil")
+		}
+		v.keyProvider = p
+		return nil
+	}
+}
+
+// WithSuccessStatus sets the status the handlers in this module answer
+// accepted webhooks with, for senders expecting, say, 204 No Content. It
+// must be a 2xx status. The default is 200.
+func WithSuccessStatus(code int) Option {
+	return func(v *Verifier) error {
+		if code < 200 || code > 299 {
+			return fmt.Errorf("webhook: success status %d is not a 2xx status", code)
+		}
+		v.successStatus = code
+		return nil
+	}
+}
+
+// WithSuccessResponse sets the body, of media type contentType, the
+// handlers in this module answer accepted webhooks with, for senders
+// expecting a specific acknowledgment. Accepted webhooks get an empty body
+// by default.
+func WithSuccessResponse(body []byte, contentType string) Option {
+	return func(v *Verifier) error {
+		if len(body) == 0 {
+			return errors.New("webhook: success response must not be empty")
+		}
+		if contentType == "" {
+			return errors.New("webhook: success response content type must not be empty")
+		}
+		v.successBody = bytes.Clone(body)
+		v.successType = contentType
+		return nil
+	}
+}
+
+// WithErrorResponder makes the handlers in this module answer rejected
+// webhooks, and webhooks whose handler failed, with fn instead of a JSON
+// body of the form {"error": "...", "request_id": "..."}, so the response
+// can follow an existing error envelope. fn receives the status StatusCode
+// maps err to, which it may replace, and err itself, which wraps one of
+// the Err* sentinels and can be matched with errors.Is.
+func WithErrorResponder(fn ErrorResponder) Option {
+	return func(v *Verifier) error {
+		if fn == nil {
+			return errors.New("webhook: error responder must not be nil")
+		}
+		v.errorResponder = fn
+		return nil
+	}
+}
+
+// WithAllowedEventTypes makes v ignore verified webhooks whose event type
+// is not one of types: ReadRequest fails with ErrEventIgnored, which the
+// handlers in this module acknowledge with their success response without
+// running the handler, and which is counted with IgnoredMetrics rather
+// than as a rejection. The signature check still runs first, so forged
+// webhooks are not acknowledged, but the type is checked before the replay
+// and duplicate checks, the sink and validators, and ignored deliveries are
+// not recorded by them, so their retries are acknowledged as well. For
+// batches and gzip bodies signed compressed the type is only known once
+// the body is parsed; deliveries found to be ignored then are released as
+// with Verifier.Release. Events of an ignored type are dropped from
+// batches.
+// All event types are accepted by default.
+func WithAllowedEventTypes(types ...EventType) Option {
+	return func(v *Verifier) error {
+		if len(types) == 0 {
+			return errors.New("webhook: no allowed event types")
+		}
+		v.allowedTypes = make(map[EventType]bool, len(types))
+		for _, t := range types {
+			if t == "" {
+				return errors.New("webhook: empty event type")
+			}
+			v.allowedTypes[t] = true
+		}
+		return nil
+	}
+}
+
+// WithBodyCanonicalizer makes v check signatures against canonicalize(body)
+// instead of the body as received, for deployments where a proxy rewrites
+// bodies on the way, for example reformatting JSON whitespace.
+// canonicalize must map the body as sent and as rewritten to the same
+// bytes, and the sender must sign those canonical bytes too, so both sides
+// have to agree on the canonicalization: OpenVidu Meet signs the body as
+// sent, which matches only if it is already in canonical form. CanonicalJSON
+// is a canonicalizer for whitespace changes. Webhooks that fail to
+// canonicalize are rejected with ErrInvalidPayload. The handlers still
+// parse the body as received. Bodies are no longer verified while they are
+// read but once fully read, and WithGzipSigning(SignedCompressed) does not
+// apply. Signatures are checked against the exact bytes by default.
+func WithBodyCanonicalizer(canonicalize func(body []byte) ([]byte, error)) Option {
+	return func(v *Verifier) error {
+		if canonicalize == nil {
+			return errors.New("webhook: body canonicalizer must not be nil")
+		}
+		v.canonicalize = canonicalize
+		return nil
+	}
+}
+
+// CanonicalJSON is a body canonicalizer for WithBodyCanonicalizer that
+// removes insignificant whitespace from a JSON body, as json.Compact does.
+// The order of object keys and the encoding of strings and numbers are
+// kept.
+func CanonicalJSON(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, body); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WithTimestampMatch makes ReadRequest reject events whose payload carries a
+// top-level "timestamp" field more than tolerance away from the timestamp
+// header with ErrTimestampMismatch. The header alone is checked against
+// WithMaxAge, so a payload timestamp that disagrees with it is a sign of
+// tampering. The field is a number in the unit set by WithTimestampUnit or
+// an RFC 3339 string; events without it pass, and events whose field is
+// neither are rejected with ErrInvalidPayload. OpenVidu Meet does not send
+// the field, so the check is off by default.
+func WithTimestampMatch(tolerance time.Duration) Option {
+	return func(v *Verifier) error {
+		if tolerance < 0 {
+			return errors.New("webhook: timestamp tolerance must not be negative")
+		}
+		v.matchTimestamp = true
+		v.timestampSlack = tolerance
+		return nil
+	}
+}
+
+// validate reports options that are valid on their own but conflict with
+// each other or have no effect.
+func (v *Verifier) validate() error {
+	if http.CanonicalHeaderKey(v.signatureHeader) == http.CanonicalHeaderKey(v.timestampHeader) {
+		return fmt.Errorf("webhook: signature and timestamp headers are both %q", v.signatureHeader)
+	}
+	if v.timestampUnit == UnitSeconds && v.maxAge < time.Second {
+		return errors.New("webhook: max age must be at least one second with UnitSeconds")
+	}
+	if v.scheme == SchemeEd25519 && v.hash != HashSHA256 {
+		return errors.New("webhook: WithHashAlgorithm does not apply to SchemeEd25519")
+	}
+	if v.scheme == SchemeEd25519 && v.deriveKey != nil {
+		return errors.New("webhook: WithKeyDeriver does not apply to SchemeEd25519")
+	}
+	if v.keyProvider != nil && (v.additionalKeys != nil || v.deriveKey != nil) {
+		return errors.New("webhook: WithAdditionalKeys and WithKeyDeriver do not apply with WithKeyProvider")
+	}
+	if v.keepSignatures && v.rejectionSink == nil {
+		return errors.New("webhook: WithRejectionSignatures requires WithRejectionSink")
+	}
+	if v.sinkSampler != nil && v.rejectionSink == nil {
+		return errors.New("webhook: WithRejectionSinkSampling requires WithRejectionSink")
+	}
+	if v.canonicalize != nil && v.gzipSigning == SignedCompressed {
+		return errors.New("webhook: WithBodyCanonicalizer does not apply to SignedCompressed")
+	}
+	if v.responses != nil && v.replay == nil {
+		return errors.New("webhook: WithResponseCache requires WithReplayCache")
+	}
+	if v.sinkFailure == SinkFailureReject && v.sink == nil {
+		return errors.New("webhook: WithSinkFailureMode requires WithEventSink")
+	}
+	if v.successBody != nil && (v.successStatus == http.StatusNoContent || v.successStatus == http.StatusResetContent) {
+		return fmt.Errorf("webhook: WithSuccessResponse does not apply to status %d", v.successStatus)
+	}
+	if v.trustedProxies > 0 && v.allowedNets == nil && (v.limiter == nil || !v.limiter.perIP) {
+		return errors.New("webhook: WithTrustedProxies requires WithAllowedCIDRs or WithPerIPRateLimit")
+	}
+	return nil
+}
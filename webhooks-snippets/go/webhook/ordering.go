@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"fmt"
+	"sync"
+)
+
+// OrderingProblem is the kind of ordering issue found by WithOrderingCheck.
+type OrderingProblem int
+
+const (
+	// OutOfOrder means the event is not newer than one already received
+	// for the same room.
+	OutOfOrder OrderingProblem = iota
+	// SequenceGap means sequence numbers were skipped: one or more events
+	// for the room are missing or have not arrived yet.
+	SequenceGap
+)
+
+func (p OrderingProblem) String() string {
+	switch p {
+	case OutOfOrder:
+		return "out_of_order"
+	case SequenceGap:
+		return "sequence_gap"
+	default:
+		return fmt.Sprintf("OrderingProblem(%d)", int(p))
+	}
+}
+
+// OrderingIssue describes a webhook that arrived out of order.
+type OrderingIssue struct {
+	Problem OrderingProblem
+	// Event is the event that arrived out of order.
+	Event *WebhookEvent
+	// Last is the sequence number of the newest event received for the
+	// room before Event or, when Event carries no sequence number, its
+	// creation date.
+	Last int64
+}
+
+// OrderingFunc is called by WithOrderingCheck for each ordering issue.
+type OrderingFunc func(issue OrderingIssue)
+
+// orderTracker remembers the newest event received for each room.
+type orderTracker struct {
+	report OrderingFunc
+
+	mu    sync.Mutex
+	rooms map[string]roomOrder
+}
+
+type roomOrder struct {
+	sequence     int64
+	creationDate int64
+}
+
+// check reports event if it is older than, or skips sequence numbers after,
+// the newest event received for its room.
+func (t *orderTracker) check(event *WebhookEvent) {
+	if event.RoomID == "" {
+		return
+	}
+
+	t.mu.Lock()
+	last := t.rooms[event.RoomID]
+	next := last
+	var issue *OrderingIssue
+	if event.Sequence != 0 {
+		switch {
+		case last.sequence != 0 && event.Sequence <= last.sequence:
+			issue = &OrderingIssue{Problem: OutOfOrder, Event: event, Last: last.sequence}
+		case last.sequence != 0 && event.Sequence > last.sequence+1:
+			issue = &OrderingIssue{Problem: SequenceGap, Event: event, Last: last.sequence}
+		}
+		next.sequence = max(last.sequence, event.Sequence)
+	} else if event.CreationDate < last.creationDate {
+		issue = &OrderingIssue{Problem: OutOfOrder, Event: event, Last: last.creationDate}
+	}
+	next.creationDate = max(last.creationDate, event.CreationDate)
+
+	if event.Type == EventRoomClosed {
+		delete(t.rooms, event.RoomID)
+	} else {
+		t.rooms[event.RoomID] = next
+	}
+	t.mu.Unlock()
+
+	if issue != nil {
+		t.report(*issue)
+	}
+}
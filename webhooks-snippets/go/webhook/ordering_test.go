@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestOrderingCheck(t *testing.T) {
+	type result struct {
+		problem OrderingProblem
+		seq     int64
+		last    int64
+	}
+	var got []result
+	v := mustVerifier(t, testKey, WithOrderingCheck(func(issue OrderingIssue) {
+		got = append(got, result{issue.Problem, issue.Event.Sequence, issue.Last})
+	}))
+
+	send := func(eventType EventType, room string, seq, created int64) {
+		t.Helper()
+		body := fmt.Appendf(nil, `{"event":%q,"creationDate":%d,"sequence":%d,"data":{"roomId":%q,"roomName":"Room"}}`, eventType, created, seq, room)
+		if _, _, err := v.ReadRequest(newRequest(body, signedHeaders(testKey, body, time.Now()))); err != nil {
+			t.Fatalf("ReadRequest() = %v, want nil", err)
+		}
+	}
+
+	send(EventMeetingStarted, "room-1", 1, 100)
+	send(EventMeetingStarted, "room-2", 7, 100) // rooms are tracked separately
+	send(EventMeetingEnded, "room-1", 3, 300)   // 2 is missing
+	send(EventMeetingStarted, "room-1", 2, 200) // 2 arrives late
+	send(EventMeetingStarted, "room-3", 0, 500)
+	send(EventMeetingEnded, "room-3", 0, 400) // no sequence: creation date is used
+	send(EventRoomClosed, "room-1", 4, 400)
+	send(EventMeetingStarted, "room-1", 1, 500) // room-1 was forgotten when closed
+
+	want := []result{
+		{SequenceGap, 3, 1},
+		{OutOfOrder, 2, 3},
+		{OutOfOrder, 0, 500},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("issues = %v, want %v", got, want)
+	}
+}
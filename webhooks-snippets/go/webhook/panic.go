@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+)
+
+// PanicError is the error a handler panic is turned into by
+// Verifier.RunHandler and WorkerPool. It wraps ErrHandlerPanic.
+type PanicError struct {
+	// Event is the type of the event being handled.
+	Event EventType
+	// Value is the value passed to panic, and Stack the stack trace of the
+	// panicking goroutine.
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%v: %s: %v", ErrHandlerPanic, e.Event, e.Value)
+}
+
+func (e *PanicError) Unwrap() error {
+	return ErrHandlerPanic
+}
+
+// PanicMetrics is implemented by Metrics and QueueMetrics that also count
+// handler panics. Verifier and WorkerPool detect it when the metrics are
+// set with WithMetrics or WithQueueMetrics. promwebhook.Metrics implements
+// it.
+type PanicMetrics interface {
+	// IncHandlerPanic counts a panic in a handler of an event of type
+	// eventType.
+	IncHandlerPanic(eventType string)
+}
+
+// callHandler calls handler with event, turning a panic into a *PanicError.
+// Only user-supplied handlers are called this way, so panics in the
+// verification code are never hidden.
+func callHandler(ctx context.Context, handler HandlerFunc, event *WebhookEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Event: event.Type, Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return handler(ctx, event)
+}
+
+// reportPanic logs p with its stack trace and counts it in metrics, if they
+// implement PanicMetrics.
+func reportPanic(ctx context.Context, logger *slog.Logger, metrics any, p *PanicError) {
+	logger.ErrorContext(ctx, "Webhook handler panicked",
+		"event", p.Event, "panic", fmt.Sprint(p.Value), "stack", string(p.Stack))
+	if m, ok := metrics.(PanicMetrics); ok {
+		m.IncHandlerPanic(string(p.Event))
+	}
+}
@@ -0,0 +1,210 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// WorkerPool processes verified webhook events in the background, so the
+// HTTP handler can acknowledge a webhook as soon as it is queued instead of
+// after slow processing finishes.
+type WorkerPool struct {
+	eventQueue
+	handler atomic.Pointer[HandlerFunc]
+	wg      sync.WaitGroup
+	busy    atomic.Int64 // events being processed
+
+	// With WithSerializeBy, events are received and claimed under recvMu,
+	// so events with the same key are processed in the order they were
+	// queued. keyed holds the events waiting for each key being processed.
+	recvMu  sync.Mutex
+	keyMu   sync.Mutex
+	keyFree *sync.Cond // signaled when an event leaves keyed
+	keyed   map[string][]*WebhookEvent
+	waiting int // events in keyed
+}
+
+// NewWorkerPool starts size workers that process events from a queue holding
+// up to queueDepth events. Register the processing function with Handle.
+func NewWorkerPool(size, queueDepth int, opts ...QueueOption) *WorkerPool {
+	p := &WorkerPool{keyed: make(map[string][]*WebhookEvent)}
+	p.keyFree = sync.NewCond(&p.keyMu)
+	p.init(queueDepth, opts)
+
+	p.wg.Add(size)
+	for range size {
+		go p.work()
+	}
+	return p
+}
+
+// Handle sets the function that processes queued events, for example a
+// Dispatcher's Dispatch method. Errors it returns are logged. A panic in
+// handler is recovered and logged with its stack trace, and counted if the
+// queue metrics implement PanicMetrics, so it does not crash the process.
+func (p *WorkerPool) Handle(handler HandlerFunc) {
+	p.handler.Store(&handler)
+}
+
+// Submit queues event for processing. Depending on the overflow policy it
+// blocks for up to the block timeout, drops the event or the oldest queued
+// one, or returns ErrQueueFull when the queue is full. It returns
+// ErrQueueClosed after Close. Submit has the HandlerFunc signature, so it
+// can be passed to DispatchHandler.
+//
+// ctx only bounds the wait for room in the queue: events are processed with
+// a context of their own, since the request context ends as soon as the
+// webhook is acknowledged.
+func (p *WorkerPool) Submit(ctx context.Context, event *WebhookEvent) error {
+	return p.offer(ctx, event)
+}
+
+// Close stops accepting events and waits until every queued event has been
+// processed, or until ctx is done. Call it after the HTTP server has shut
+// down, so events acknowledged by the last requests are not lost. Submits
+// waiting for room in the queue fail with ErrQueueClosed.
+//
+// If ctx is done first, Close logs how many events were abandoned, either
+// still queued or being processed, and returns an error wrapping
+// ctx.Err(). The workers keep processing them in the background until the
+// process exits.
+func (p *WorkerPool) Close(ctx context.Context) error {
+	p.close()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.keyMu.Lock()
+		queued, busy := len(p.ch)+p.waiting, p.busy.Load()
+		p.keyMu.Unlock()
+		p.logger.Warn("Abandoning unprocessed webhooks", "queued", queued, "in_progress", busy)
+		return fmt.Errorf("webhook: %d queued and %d in-progress events not processed: %w", queued, busy, ctx.Err())
+	}
+}
+
+func (p *WorkerPool) work() {
+	defer p.wg.Done()
+
+	if p.serializeBy == nil {
+		for event := range p.ch {
+			p.run(event)
+		}
+		return
+	}
+	for {
+		p.recvMu.Lock()
+		event, ok := <-p.ch
+		var key string
+		claimed := false
+		if ok {
+			key = p.serializeBy(event)
+			claimed = p.claim(key, event)
+		}
+		p.recvMu.Unlock()
+		if !ok {
+			return
+		}
+		// The worker that claimed a key processes its waiting events too.
+		for claimed && event != nil {
+			p.run(event)
+			event = p.release(key)
+		}
+	}
+}
+
+// claim reports whether event, whose key is key, can be processed now. If
+// an event with the same key is being processed, event is queued behind it
+// instead, waiting for room if too many events are waiting already.
+func (p *WorkerPool) claim(key string, event *WebhookEvent) bool {
+	if key == "" {
+		return true
+	}
+	p.keyMu.Lock()
+	defer p.keyMu.Unlock()
+
+	waiting, busy := p.keyed[key]
+	if !busy {
+		p.keyed[key] = nil
+		return true
+	}
+	p.keyed[key] = append(waiting, event)
+	p.waiting++
+	for p.waiting > max(cap(p.ch), 1) {
+		p.keyFree.Wait()
+	}
+	return false
+}
+
+// release returns the next event waiting for key, or nil once there is
+// none and the key is free again.
+func (p *WorkerPool) release(key string) *WebhookEvent {
+	if key == "" {
+		return nil
+	}
+	p.keyMu.Lock()
+	defer p.keyMu.Unlock()
+
+	waiting := p.keyed[key]
+	if len(waiting) == 0 {
+		delete(p.keyed, key)
+		return nil
+	}
+	event := waiting[0]
+	waiting[0] = nil
+	p.keyed[key] = waiting[1:]
+	p.waiting--
+	p.keyFree.Signal()
+	return event
+}
+
+// run processes event with the registered handler, if any.
+func (p *WorkerPool) run(event *WebhookEvent) {
+	handler := p.handler.Load()
+	if handler == nil {
+		p.logger.WarnContext(ContextWithRequestID(context.Background(), event.RequestID),
+			"No webhook handler registered, dropping event", "event", event.Type)
+		return
+	}
+	p.busy.Add(1)
+	p.process(*handler, event)
+	p.busy.Add(-1)
+}
+
+// process handles event, logging failures and storing the event in the
+// dead-letter sink, if any, when its handler fails or panics. The handler's
+// context carries the event's request id.
+func (p *WorkerPool) process(handler HandlerFunc, event *WebhookEvent) {
+	ctx := ContextWithRequestID(context.Background(), event.RequestID)
+	err := callHandler(ctx, handler, event)
+	if err == nil {
+		return
+	}
+
+	var pe *PanicError
+	if errors.As(err, &pe) {
+		reportPanic(ctx, p.logger, p.metrics, pe)
+	} else {
+		p.logger.ErrorContext(ctx, "Failed to handle webhook", "event", event.Type, "room_id", event.RoomID, "error", err)
+	}
+	if p.deadLetter == nil {
+		return
+	}
+	raw, err := json.Marshal(event)
+	if err == nil {
+		err = p.deadLetter.Store(ctx, event, raw)
+	}
+	if err != nil {
+		p.logger.ErrorContext(ctx, "Failed to store unprocessed webhook", "event", event.Type, "error", err)
+	}
+}
@@ -0,0 +1,187 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolProcessesEvents(t *testing.T) {
+	pool := NewWorkerPool(4, 10)
+
+	var processed atomic.Int32
+	pool.Handle(func(ctx context.Context, event *WebhookEvent) error {
+		processed.Add(1)
+		return nil
+	})
+
+	for range 20 {
+		if err := pool.Submit(context.Background(), &WebhookEvent{Type: EventMeetingStarted}); err != nil {
+			t.Fatalf("Submit() = %v, want nil", err)
+		}
+	}
+	pool.Close(context.Background())
+
+	if got := processed.Load(); got != 20 {
+		t.Errorf("processed %d events, want 20", got)
+	}
+	if err := pool.Submit(context.Background(), &WebhookEvent{}); !errors.Is(err, ErrQueueClosed) || !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Submit() after Close = %v, want %v", err, ErrQueueClosed)
+	}
+}
+
+func TestWorkerPoolOverflow(t *testing.T) {
+	tests := []struct {
+		policy OverflowPolicy
+		want   error
+	}{
+		{PolicyDropNewest, nil},
+		{PolicyDropOldest, nil},
+		{PolicyReject503, ErrQueueFull},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			release := make(chan struct{})
+			started := make(chan struct{}, 1)
+			pool := NewWorkerPool(1, 1, WithOverflowPolicy(tt.policy))
+			pool.Handle(func(ctx context.Context, event *WebhookEvent) error {
+				started <- struct{}{}
+				<-release
+				return nil
+			})
+
+			// One event keeps the worker busy and another fills the queue.
+			pool.Submit(context.Background(), &WebhookEvent{})
+			<-started
+			pool.Submit(context.Background(), &WebhookEvent{})
+
+			if err := pool.Submit(context.Background(), &WebhookEvent{}); !errors.Is(err, tt.want) {
+				t.Errorf("Submit() on full queue = %v, want %v", err, tt.want)
+			}
+
+			close(release)
+			pool.Close(context.Background())
+		})
+	}
+}
+
+func TestWorkerPoolSerializeBy(t *testing.T) {
+	pool := NewWorkerPool(4, 100, WithSerializeBy(func(e *WebhookEvent) string { return e.RoomID }))
+
+	var mu sync.Mutex
+	running := map[string]int{}
+	order := map[string][]int64{}
+	otherRoom := make(chan struct{})
+	pool.Handle(func(ctx context.Context, event *WebhookEvent) error {
+		mu.Lock()
+		running[event.RoomID]++
+		if running[event.RoomID] > 1 {
+			t.Errorf("events for %s processed concurrently", event.RoomID)
+		}
+		order[event.RoomID] = append(order[event.RoomID], event.Sequence)
+		mu.Unlock()
+
+		switch {
+		case event.RoomID == "room-a" && event.Sequence == 0:
+			// Holds room-a until room-b has been processed.
+			select {
+			case <-otherRoom:
+			case <-time.After(5 * time.Second):
+				t.Error("room-b was not processed while room-a was busy")
+			}
+		case event.RoomID == "room-b" && event.Sequence == 0:
+			close(otherRoom)
+		default:
+			time.Sleep(time.Millisecond)
+		}
+
+		mu.Lock()
+		running[event.RoomID]--
+		mu.Unlock()
+		return nil
+	})
+
+	for i := range int64(10) {
+		pool.Submit(context.Background(), &WebhookEvent{RoomID: "room-a", Sequence: i})
+	}
+	for i := range int64(10) {
+		pool.Submit(context.Background(), &WebhookEvent{RoomID: "room-b", Sequence: i})
+	}
+	pool.Close(context.Background())
+
+	for _, room := range []string{"room-a", "room-b"} {
+		if got := order[room]; !slices.IsSorted(got) || len(got) != 10 {
+			t.Errorf("%s events processed in order %v, want 0 to 9", room, got)
+		}
+	}
+
+	// Waiting events are bounded by the queue depth, so a single busy key
+	// holds back the other workers instead of piling up events.
+	pool = NewWorkerPool(2, 1, WithSerializeBy(func(e *WebhookEvent) string { return e.RoomID }))
+	var got []int64
+	pool.Handle(func(ctx context.Context, event *WebhookEvent) error {
+		got = append(got, event.Sequence)
+		return nil
+	})
+	for i := range int64(20) {
+		if err := pool.Submit(context.Background(), &WebhookEvent{RoomID: "room-a", Sequence: i}); err != nil {
+			t.Fatalf("Submit() = %v, want nil", err)
+		}
+	}
+	pool.Close(context.Background())
+	if !slices.IsSorted(got) || len(got) != 20 {
+		t.Errorf("events processed in order %v, want 0 to 19", got)
+	}
+}
+
+func TestWorkerPoolCloseDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	pool := NewWorkerPool(1, 10)
+	pool.Handle(func(ctx context.Context, event *WebhookEvent) error {
+		<-release
+		return nil
+	})
+	for range 3 {
+		pool.Submit(context.Background(), &WebhookEvent{})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pool.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := pool.Submit(context.Background(), &WebhookEvent{}); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Submit() after Close = %v, want %v", err, ErrQueueClosed)
+	}
+}
+
+func TestWorkerPoolPanic(t *testing.T) {
+	metrics := &panicCounter{}
+	deadLetter := &MemorySink{}
+	pool := NewWorkerPool(1, 10, WithQueueMetrics(metrics), WithQueueDeadLetter(deadLetter))
+
+	var processed atomic.Int32
+	pool.Handle(func(ctx context.Context, event *WebhookEvent) error {
+		if event.Type == EventMeetingEnded {
+			panic("boom")
+		}
+		processed.Add(1)
+		return nil
+	})
+	pool.Submit(context.Background(), &WebhookEvent{Type: EventMeetingEnded})
+	pool.Submit(context.Background(), &WebhookEvent{Type: EventMeetingStarted})
+	pool.Close(context.Background())
+
+	// The worker survives the panic and goes on with the next event.
+	if processed.Load() != 1 || metrics.panics.Load() != 1 {
+		t.Errorf("processed %d events with %d panics, want 1 and 1", processed.Load(), metrics.panics.Load())
+	}
+	if events := deadLetter.Events(); len(events) != 1 || events[0].Event != EventMeetingEnded {
+		t.Errorf("dead letters = %+v, want the panicking event", events)
+	}
+}
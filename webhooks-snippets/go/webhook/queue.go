@@ -0,0 +1,259 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultBlockTimeout is how long PolicyBlock waits for room in a full
+// queue unless overridden with WithBlockTimeout.
+const DefaultBlockTimeout = 5 * time.Second
+
+// OverflowPolicy decides what happens when an event is offered to a full
+// WorkerPool or EventStream.
+type OverflowPolicy int
+
+const (
+	// PolicyBlock waits until there is room, for up to the block timeout
+	// set with WithBlockTimeout and no longer than the context passed along
+	// with the event. If the wait times out the event fails with
+	// ErrQueueFull, so the webhook is answered with 503.
+	PolicyBlock OverflowPolicy = iota
+	// PolicyDropNewest discards the offered event and logs a warning.
+	PolicyDropNewest
+	// PolicyReject503 fails with ErrQueueFull so the webhook is answered
+	// with 503 and the sender retries it later.
+	PolicyReject503
+	// PolicyDropOldest discards the oldest queued event to make room for
+	// the offered one and logs a warning. It favors recent events, whose
+	// state is usually more relevant, over old ones.
+	PolicyDropOldest
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case PolicyBlock:
+		return "block"
+	case PolicyDropNewest:
+		return "drop-newest"
+	case PolicyReject503:
+		return "reject-503"
+	case PolicyDropOldest:
+		return "drop-oldest"
+	default:
+		return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+	}
+}
+
+// QueueMetrics receives overflow counts from WorkerPool and EventStream.
+// promwebhook.Metrics implements it.
+type QueueMetrics interface {
+	// IncOverflow counts an event offered to a full queue, labeled by the
+	// policy that handled it. It is called once per overflow, whatever its
+	// outcome, so each policy's count shows how often it fired.
+	IncOverflow(policy OverflowPolicy)
+}
+
+// QueueOption configures a WorkerPool or an EventStream.
+type QueueOption func(*queueConfig)
+
+type queueConfig struct {
+	policy       OverflowPolicy
+	blockTimeout time.Duration
+	logger       *slog.Logger
+	metrics      QueueMetrics
+	deadLetter   EventSink
+	serializeBy  func(*WebhookEvent) string
+}
+
+// WithOverflowPolicy sets what happens when the queue is full. The default
+// is PolicyBlock.
+func WithOverflowPolicy(policy OverflowPolicy) QueueOption {
+	return func(c *queueConfig) {
+		c.policy = policy
+	}
+}
+
+// WithBlockTimeout sets how long PolicyBlock waits for room in a full
+// queue before failing with ErrQueueFull. Zero or a negative d waits for as
+// long as the event's context allows. The default is DefaultBlockTimeout.
+func WithBlockTimeout(d time.Duration) QueueOption {
+	return func(c *queueConfig) {
+		c.blockTimeout = d
+	}
+}
+
+// WithQueueDeadLetter stores events whose WorkerPool handler failed or
+// panicked in sink, so they can be processed again later. The stored raw
+// body is the event marshaled as JSON. It has no effect on an EventStream.
+// Failed events are only logged by default.
+func WithQueueDeadLetter(sink EventSink) QueueOption {
+	return func(c *queueConfig) {
+		c.deadLetter = sink
+	}
+}
+
+// WithSerializeBy makes a WorkerPool process events with the same key, as
+// returned by key, one at a time and in the order they were queued, while
+// events with different keys are still processed concurrently. Use it for
+// handlers that must not run concurrently for the same room, for example
+// with a key returning the event's RoomID. Events with an empty key are
+// not serialized.
+//
+// An event whose key is being processed waits in a queue of its own key,
+// and up to as many events as the queue holds can wait this way; beyond
+// that the workers stop taking events until one finishes, so the queue
+// fills up and the overflow policy applies. It has no effect on an
+// EventStream. Events are processed as soon as a worker is free by
+// default.
+func WithSerializeBy(key func(*WebhookEvent) string) QueueOption {
+	return func(c *queueConfig) {
+		c.serializeBy = key
+	}
+}
+
+// WithQueueLogger sets the logger used to report dropped events and handler
+// errors. Nothing is logged by default.
+func WithQueueLogger(logger *slog.Logger) QueueOption {
+	return func(c *queueConfig) {
+		if logger != nil {
+			logger = withRequestIDs(logger)
+		}
+		c.logger = logger
+	}
+}
+
+// WithQueueMetrics reports queue overflows to m. No metrics are collected
+// by default.
+func WithQueueMetrics(m QueueMetrics) QueueOption {
+	return func(c *queueConfig) {
+		c.metrics = m
+	}
+}
+
+// eventQueue is the bounded channel shared by WorkerPool and EventStream.
+type eventQueue struct {
+	queueConfig
+	ch chan *WebhookEvent
+
+	mu      sync.RWMutex // guards closed and sends on ch
+	closed  bool
+	done    chan struct{}  // closed by close to wake up blocked offers
+	blocked sync.WaitGroup // offers waiting for room without holding mu
+}
+
+func (q *eventQueue) init(depth int, opts []QueueOption) {
+	q.queueConfig = queueConfig{
+		policy:       PolicyBlock,
+		blockTimeout: DefaultBlockTimeout,
+		logger:       slog.New(slog.DiscardHandler),
+	}
+	for _, opt := range opts {
+		opt(&q.queueConfig)
+	}
+	q.ch = make(chan *WebhookEvent, depth)
+	q.done = make(chan struct{})
+}
+
+// offer adds event to the queue, applying the overflow policy if it is full.
+func (q *eventQueue) offer(ctx context.Context, event *WebhookEvent) error {
+	q.mu.RLock()
+	if q.closed {
+		q.mu.RUnlock()
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.ch <- event:
+		q.mu.RUnlock()
+		return nil
+	default:
+	}
+
+	if q.policy == PolicyBlock {
+		// Waiting for room must not hold mu, or close would wait for the
+		// whole timeout. close instead wakes the wait up and only closes
+		// ch once it has given up.
+		q.blocked.Add(1)
+		q.mu.RUnlock()
+		defer q.blocked.Done()
+	} else {
+		defer q.mu.RUnlock()
+	}
+
+	if q.metrics != nil {
+		q.metrics.IncOverflow(q.policy)
+	}
+
+	switch q.policy {
+	case PolicyDropNewest:
+		q.logger.WarnContext(ctx, "Webhook queue full, dropping event", "event", event.Type, "room_id", event.RoomID)
+		return nil
+	case PolicyReject503:
+		return ErrQueueFull
+	case PolicyDropOldest:
+		q.dropOldest(ctx, event)
+		return nil
+	default:
+		return q.block(ctx, event)
+	}
+}
+
+// dropOldest discards queued events until event fits. Receivers may empty
+// the queue at the same time, so nothing may need to be discarded.
+func (q *eventQueue) dropOldest(ctx context.Context, event *WebhookEvent) {
+	for {
+		select {
+		case q.ch <- event:
+			return
+		default:
+		}
+		select {
+		case old := <-q.ch:
+			q.logger.WarnContext(ctx, "Webhook queue full, dropping oldest event", "event", old.Type, "room_id", old.RoomID)
+		default:
+		}
+	}
+}
+
+// block waits for room for event for up to the block timeout.
+func (q *eventQueue) block(ctx context.Context, event *WebhookEvent) error {
+	var timeout <-chan time.Time
+	if q.blockTimeout > 0 {
+		timer := time.NewTimer(q.blockTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case q.ch <- event:
+		return nil
+	case <-timeout:
+		q.logger.WarnContext(ctx, "Webhook queue full, timed out waiting for room", "event", event.Type, "room_id", event.RoomID, "timeout", q.blockTimeout)
+		return ErrQueueFull
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrHandlerCanceled, ctx.Err())
+	case <-q.done:
+		return ErrQueueClosed
+	}
+}
+
+// close stops accepting events and closes the channel. Events already queued
+// can still be received. Offers blocked waiting for room fail with
+// ErrQueueClosed.
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.done)
+	q.mu.Unlock()
+
+	q.blocked.Wait()
+	close(q.ch)
+}
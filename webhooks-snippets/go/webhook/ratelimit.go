@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"container/list"
+	"net/netip"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ipLimiterIdle is how long a per-IP bucket is kept after its last request.
+const ipLimiterIdle = 10 * time.Minute
+
+// MaxRateLimitedIPs bounds the number of remote addresses
+// WithPerIPRateLimit keeps a bucket for. When a new address is seen while
+// that many are kept, the bucket of the address that has been idle the
+// longest is dropped, and that address starts again with a full burst.
+const MaxRateLimitedIPs = 10000
+
+// rateLimiter sheds requests above a global and/or per-remote-IP rate.
+type rateLimiter struct {
+	global *rate.Limiter
+
+	perIP   bool
+	ipRate  rate.Limit
+	ipBurst int
+	mu      sync.Mutex
+	ips     map[string]*list.Element
+	idle    list.List // of *ipLimiter, most recently seen first
+}
+
+type ipLimiter struct {
+	key      string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func (l *rateLimiter) allow(ip string, now time.Time) bool {
+	if l.global != nil && !l.global.AllowN(now, 1) {
+		return false
+	}
+	if !l.perIP {
+		return true
+	}
+	return l.ipLimiter(ipKey(ip), now).AllowN(now, 1)
+}
+
+func (l *rateLimiter) ipLimiter(key string, now time.Time) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for e := l.idle.Back(); e != nil && now.Sub(e.Value.(*ipLimiter).lastSeen) > ipLimiterIdle; e = l.idle.Back() {
+		l.remove(e)
+	}
+
+	if l.ips == nil {
+		l.ips = make(map[string]*list.Element)
+	}
+	e, ok := l.ips[key]
+	if ok {
+		l.idle.MoveToFront(e)
+	} else {
+		if l.idle.Len() >= MaxRateLimitedIPs {
+			l.remove(l.idle.Back())
+		}
+		e = l.idle.PushFront(&ipLimiter{key: key, limiter: rate.NewLimiter(l.ipRate, l.ipBurst)})
+		l.ips[key] = e
+	}
+	entry := e.Value.(*ipLimiter)
+	entry.lastSeen = now
+	return entry.limiter
+}
+
+func (l *rateLimiter) remove(e *list.Element) {
+	l.idle.Remove(e)
+	delete(l.ips, e.Value.(*ipLimiter).key)
+}
+
+// ipKey returns the key of the bucket ip is limited by. IPv6 clients
+// usually get a whole /64, so it is limited as a single address; otherwise
+// a client could sidestep its limit, and fill the table, by switching
+// addresses within it.
+func ipKey(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap()
+	if addr.Is4() {
+		return addr.String()
+	}
+	prefix, _ := addr.Prefix(64)
+	return prefix.String()
+}
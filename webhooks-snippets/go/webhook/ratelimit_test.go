@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimiterBounded(t *testing.T) {
+	l := &rateLimiter{perIP: true, ipRate: rate.Limit(0.001), ipBurst: 1}
+	start := time.UnixMilli(1700000000000)
+	for i := range MaxRateLimitedIPs + 1 {
+		l.allow(fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff), start.Add(time.Duration(i)*time.Millisecond))
+	}
+
+	if len(l.ips) != MaxRateLimitedIPs || l.idle.Len() != MaxRateLimitedIPs {
+		t.Errorf("kept %d buckets, want %d", len(l.ips), MaxRateLimitedIPs)
+	}
+	// The address that has been idle the longest lost its bucket.
+	if _, ok := l.ips["10.0.0.0"]; ok {
+		t.Error("oldest bucket was not evicted")
+	}
+	if _, ok := l.ips["10.0.0.1"]; !ok {
+		t.Error("second oldest bucket was evicted")
+	}
+}
+
+func TestRateLimiterIdleBucketsDropped(t *testing.T) {
+	l := &rateLimiter{perIP: true, ipRate: rate.Limit(0.001), ipBurst: 1}
+	start := time.UnixMilli(1700000000000)
+	l.allow("192.0.2.1", start)
+	l.allow("192.0.2.2", start.Add(ipLimiterIdle))
+
+	if !l.allow("192.0.2.3", start.Add(ipLimiterIdle+time.Second)) {
+		t.Fatal("first request from a new address was limited")
+	}
+	if _, ok := l.ips["192.0.2.1"]; ok {
+		t.Error("idle bucket was kept")
+	}
+	if len(l.ips) != 2 {
+		t.Errorf("kept %d buckets, want 2", len(l.ips))
+	}
+}
+
+func TestRateLimiterIPv6Prefix(t *testing.T) {
+	l := &rateLimiter{perIP: true, ipRate: rate.Limit(0.001), ipBurst: 1}
+	now := time.UnixMilli(1700000000000)
+
+	if !l.allow("2001:db8:1:2::1", now) {
+		t.Fatal("first request was limited")
+	}
+	if l.allow("2001:db8:1:2::ffff", now) {
+		t.Error("another address in the same /64 got a bucket of its own")
+	}
+	if !l.allow("2001:db8:1:3::1", now) {
+		t.Error("address in another /64 was limited")
+	}
+	if !l.allow("::ffff:192.0.2.1", now) || l.allow("192.0.2.1", now) {
+		t.Error("IPv4-mapped address not limited as its IPv4 address")
+	}
+}
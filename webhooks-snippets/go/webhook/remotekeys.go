@@ -0,0 +1,288 @@
+package webhook
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultRemoteKeyInterval is how often RemoteKeyProvider refreshes its
+	// keys by default.
+	DefaultRemoteKeyInterval = 5 * time.Minute
+	// DefaultRemoteKeyTimeout bounds each request RemoteKeyProvider makes
+	// by default.
+	DefaultRemoteKeyTimeout = 10 * time.Second
+
+	// maxKeySetSize bounds the key set documents RemoteKeyProvider reads.
+	maxKeySetSize = 1 << 20
+)
+
+// KeyRefreshMetrics receives refresh failures from RemoteKeyProvider.
+// promwebhook.Metrics implements it.
+type KeyRefreshMetrics interface {
+	// IncKeyRefreshFailure counts a failed attempt to refresh the keys.
+	IncKeyRefreshFailure()
+}
+
+// RemoteKeyProvider is a KeyProvider that fetches the accepted keys from a
+// URL and refreshes them periodically in the background until Close is
+// called, so that keys can be rotated centrally without redeploying
+// receivers. The same keys are accepted for every webhook.
+//
+// The URL must serve a JSON Web Key Set holding symmetric keys ("kty":
+// "oct", with the key bytes base64url-encoded in "k") for HMAC signatures,
+// or Ed25519 public keys ("kty": "OKP", "crv": "Ed25519", with the key in
+// "x") for SchemeEd25519, as chosen with WithRemoteKeyScheme. Keys of other
+// types are ignored, so that a public key published in the same set is
+// never used as an HMAC secret. Responses are revalidated with their ETag,
+// if any.
+//
+// When a refresh fails, the keys from the last successful one stay in use
+// and the failure is logged and counted with KeyRefreshMetrics.
+type RemoteKeyProvider struct {
+	url      string
+	client   *http.Client
+	interval time.Duration
+	logger   *slog.Logger
+	metrics  KeyRefreshMetrics
+	scheme   SignatureScheme
+
+	keys atomic.Pointer[[][]byte]
+
+	mu   sync.Mutex // serializes refreshes
+	etag string
+
+	done chan struct{}
+	once sync.Once
+}
+
+// RemoteKeyOption configures a RemoteKeyProvider.
+type RemoteKeyOption func(*RemoteKeyProvider) error
+
+// NewRemoteKeyProvider returns a RemoteKeyProvider that fetches its keys
+// from rawURL. The first fetch is made with ctx before it returns, and
+// NewRemoteKeyProvider fails if it does, since there are no keys to fall
+// back on yet.
+func NewRemoteKeyProvider(ctx context.Context, rawURL string, opts ...RemoteKeyOption) (*RemoteKeyProvider, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("webhook: invalid key set URL %q", rawURL)
+	}
+
+	p := &RemoteKeyProvider{
+		url:      rawURL,
+		client:   &http.Client{Timeout: DefaultRemoteKeyTimeout},
+		interval: DefaultRemoteKeyInterval,
+		logger:   slog.New(slog.DiscardHandler),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	go p.refreshLoop()
+	return p, nil
+}
+
+// WithRemoteKeyClient sets the HTTP client used to fetch the keys. The
+// default is a client with a DefaultRemoteKeyTimeout timeout.
+func WithRemoteKeyClient(c *http.Client) RemoteKeyOption {
+	return func(p *RemoteKeyProvider) error {
+		if c == nil {
+			return errors.New("webhook: key set client must not be nil")
+		}
+		p.client = c
+		return nil
+	}
+}
+
+// WithRemoteKeyInterval sets how often the keys are refreshed. The default
+// is DefaultRemoteKeyInterval.
+func WithRemoteKeyInterval(d time.Duration) RemoteKeyOption {
+	return func(p *RemoteKeyProvider) error {
+		if d <= 0 {
+			return errors.New("webhook: key refresh interval must be positive")
+		}
+		p.interval = d
+		return nil
+	}
+}
+
+// WithRemoteKeyLogger sets the logger failed refreshes are reported to. By
+// default nothing is logged.
+func WithRemoteKeyLogger(l *slog.Logger) RemoteKeyOption {
+	return func(p *RemoteKeyProvider) error {
+		if l == nil {
+			return errors.New("webhook: logger must not be nil")
+		}
+		p.logger = withRequestIDs(l)
+		return nil
+	}
+}
+
+// WithRemoteKeyMetrics reports failed refreshes to m. No metrics are
+// collected by default.
+func WithRemoteKeyMetrics(m KeyRefreshMetrics) RemoteKeyOption {
+	return func(p *RemoteKeyProvider) error {
+		if m == nil {
+			return errors.New("webhook: metrics must not be nil")
+		}
+		p.metrics = m
+		return nil
+	}
+}
+
+// WithRemoteKeyScheme sets the signature scheme the keys are used with,
+// which must match the Verifier's WithSignatureScheme: only symmetric keys
+// are used with SchemeHMAC, and only Ed25519 public keys with
+// SchemeEd25519. The default is SchemeHMAC.
+func WithRemoteKeyScheme(s SignatureScheme) RemoteKeyOption {
+	return func(p *RemoteKeyProvider) error {
+		switch s {
+		case SchemeHMAC, SchemeEd25519:
+			p.scheme = s
+			return nil
+		default:
+			return fmt.Errorf("webhook: unsupported signature scheme %v", s)
+		}
+	}
+}
+
+// KeyForRequest implements KeyProvider. It returns the keys from the last
+// successful refresh.
+func (p *RemoteKeyProvider) KeyForRequest(http.Header) ([][]byte, error) {
+	return *p.keys.Load(), nil
+}
+
+// Refresh fetches the keys now. On error the current keys are kept.
+func (p *RemoteKeyProvider) Refresh(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	keys, etag, err := p.fetch(ctx)
+	if err != nil {
+		return fmt.Errorf("webhook: refreshing keys from %s: %w", p.url, err)
+	}
+	if keys != nil {
+		p.keys.Store(&keys)
+		p.etag = etag
+	}
+	return nil
+}
+
+// Close stops the background refresh.
+func (p *RemoteKeyProvider) Close() {
+	p.once.Do(func() { close(p.done) })
+}
+
+func (p *RemoteKeyProvider) refreshLoop() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.Refresh(context.Background()); err != nil {
+				p.logger.Warn("Failed to refresh webhook keys, keeping the current ones", "url", p.url, "error", err)
+				if p.metrics != nil {
+					p.metrics.IncKeyRefreshFailure()
+				}
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// fetch returns the keys served at p.url and their ETag, or nil keys if
+// they have not changed since the last fetch.
+func (p *RemoteKeyProvider) fetch(ctx context.Context) ([][]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.etag != "" {
+		req.Header.Set("If-None-Match", p.etag)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && p.keys.Load() != nil:
+		return nil, "", nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	body, err := readBody(resp.Body, maxKeySetSize)
+	if err != nil {
+		return nil, "", err
+	}
+	keys, err := parseKeySet(body, p.scheme)
+	if err != nil {
+		return nil, "", err
+	}
+	return keys, resp.Header.Get("ETag"), nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	K   string `json:"k"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+}
+
+// parseKeySet returns the key bytes of the keys in a JSON Web Key Set
+// that can be used with scheme.
+func parseKeySet(body []byte, scheme SignatureScheme) ([][]byte, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, fmt.Errorf("invalid key set: %v", err)
+	}
+
+	var keys [][]byte
+	for _, jwk := range set.Keys {
+		var encoded string
+		switch {
+		case scheme == SchemeHMAC && jwk.Kty == "oct":
+			encoded = jwk.K
+		case scheme == SchemeEd25519 && jwk.Kty == "OKP" && jwk.Crv == "Ed25519":
+			encoded = jwk.X
+		default:
+			continue
+		}
+		key, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err != nil || len(key) == 0 {
+			return nil, fmt.Errorf("invalid key %q", jwk.Kid)
+		}
+		if jwk.Kty == "OKP" && len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key %q", jwk.Kid)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("key set holds no keys usable with %v", scheme)
+	}
+	return keys, nil
+}
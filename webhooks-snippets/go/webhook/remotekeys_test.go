@@ -0,0 +1,194 @@
+package webhook
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// keySetServer serves a JSON Web Key Set holding key, or fails with status
+// if it is set.
+type keySetServer struct {
+	mu     sync.Mutex
+	key    string
+	status int
+	hits   int
+}
+
+func (s *keySetServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hits++
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	etag := `"` + s.key + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	fmt.Fprintf(w, `{"keys":[{"kty":"oct","kid":"current","k":%q},{"kty":"RSA","kid":"other"}]}`,
+		base64.RawURLEncoding.EncodeToString([]byte(s.key)))
+}
+
+func (s *keySetServer) set(key string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.key, s.status = key, status
+}
+
+type refreshCounter struct{ failures atomic.Int32 }
+
+func (c *refreshCounter) IncKeyRefreshFailure() { c.failures.Add(1) }
+
+func TestRemoteKeyProvider(t *testing.T) {
+	body := []byte(testBody)
+	keys := &keySetServer{key: testKey}
+	srv := httptest.NewServer(keys)
+	defer srv.Close()
+
+	p, err := NewRemoteKeyProvider(t.Context(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	v := mustVerifier(t, "", WithKeyProvider(p))
+
+	if err := v.Verify(body, signedHeaders(testKey, body, time.Now())); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+
+	// An unchanged key set is revalidated, not parsed again.
+	if err := p.Refresh(t.Context()); err != nil {
+		t.Fatalf("Refresh() unchanged = %v", err)
+	}
+
+	// Failed refreshes keep the last known good keys.
+	keys.set("rotated-key", http.StatusInternalServerError)
+	if err := p.Refresh(t.Context()); err == nil {
+		t.Error("Refresh() succeeded on a failing endpoint")
+	}
+	if err := v.Verify(body, signedHeaders(testKey, body, time.Now())); err != nil {
+		t.Errorf("Verify() after failed refresh = %v", err)
+	}
+
+	keys.set("rotated-key", 0)
+	if err := p.Refresh(t.Context()); err != nil {
+		t.Fatalf("Refresh() = %v", err)
+	}
+	if err := v.Verify(body, signedHeaders("rotated-key", body, time.Now())); err != nil {
+		t.Errorf("Verify() with rotated key = %v", err)
+	}
+	if err := v.Verify(body, signedHeaders(testKey, body, time.Now())); err != ErrSignatureMismatch {
+		t.Errorf("Verify() with old key = %v, want %v", err, ErrSignatureMismatch)
+	}
+}
+
+func TestRemoteKeyProviderBackgroundRefresh(t *testing.T) {
+	keys := &keySetServer{key: testKey}
+	srv := httptest.NewServer(keys)
+	defer srv.Close()
+
+	metrics := &refreshCounter{}
+	p, err := NewRemoteKeyProvider(t.Context(), srv.URL,
+		WithRemoteKeyInterval(10*time.Millisecond), WithRemoteKeyMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	keys.set(testKey, http.StatusServiceUnavailable)
+	deadline := time.Now().Add(time.Second)
+	for metrics.failures.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if metrics.failures.Load() == 0 {
+		t.Fatal("failed background refresh was not counted")
+	}
+	if got, _ := p.KeyForRequest(nil); len(got) != 1 || string(got[0]) != testKey {
+		t.Errorf("keys after failed refresh = %q, want %q", got, testKey)
+	}
+}
+
+func TestRemoteKeyProviderMixedKeySet(t *testing.T) {
+	body := []byte(testBody)
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"keys":[{"kty":"oct","kid":"secret","k":%q},{"kty":"OKP","crv":"Ed25519","kid":"public","x":%q}]}`,
+			encode([]byte(testKey)), encode(pub))
+	}))
+	defer srv.Close()
+
+	hmacKeys, err := NewRemoteKeyProvider(t.Context(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hmacKeys.Close()
+	edKeys, err := NewRemoteKeyProvider(t.Context(), srv.URL, WithRemoteKeyScheme(SchemeEd25519))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer edKeys.Close()
+
+	// The public key is published, so anyone could sign with it as an
+	// HMAC secret.
+	v := mustVerifier(t, "", WithKeyProvider(hmacKeys))
+	if err := v.Verify(body, signedHeaders(testKey, body, time.Now())); err != nil {
+		t.Errorf("Verify() with the secret = %v", err)
+	}
+	if err := v.Verify(body, signedHeaders(string(pub), body, time.Now())); !errors.Is(err, ErrSignatureMismatch) {
+		t.Errorf("Verify() with the public key as secret = %v, want %v", err, ErrSignatureMismatch)
+	}
+
+	v = mustVerifier(t, "", WithKeyProvider(edKeys), WithSignatureScheme(SchemeEd25519))
+	signature, timestamp := SignEd25519(priv, body, time.Now())
+	headers := http.Header{}
+	headers.Set("x-signature", signature)
+	headers.Set("x-timestamp", timestamp)
+	if err := v.Verify(body, headers); err != nil {
+		t.Errorf("Verify() with Ed25519 = %v", err)
+	}
+	if keys, _ := edKeys.KeyForRequest(nil); len(keys) != 1 {
+		t.Errorf("Ed25519 provider holds %d keys, want 1", len(keys))
+	}
+}
+
+func TestNewRemoteKeyProviderErrors(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer failing.Close()
+	empty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"keys":[]}`)
+	}))
+	defer empty.Close()
+
+	for _, url := range []string{"", "ftp://keys.example.com", failing.URL, empty.URL} {
+		if p, err := NewRemoteKeyProvider(context.Background(), url); err == nil {
+			p.Close()
+			t.Errorf("NewRemoteKeyProvider(%q) succeeded", url)
+		}
+	}
+	if _, err := NewRemoteKeyProvider(context.Background(), "https://keys.example.com", WithRemoteKeyInterval(0)); err == nil {
+		t.Error("NewRemoteKeyProvider with zero interval succeeded")
+	}
+	if _, err := NewRemoteKeyProvider(context.Background(), "https://keys.example.com", WithRemoteKeyScheme(SignatureScheme(99))); err == nil {
+		t.Error("NewRemoteKeyProvider with unknown scheme succeeded")
+	}
+}
@@ -0,0 +1,189 @@
+package webhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// ReplayStore remembers webhook deliveries that have already been accepted so
+// that replays of the same delivery can be rejected. Implementations must be
+// safe for concurrent use; a shared store such as Redis lets several
+// receivers reject each other's replays.
+type ReplayStore interface {
+	// Seen reports whether id was remembered and has not yet expired.
+	Seen(ctx context.Context, id string) bool
+	// Remember records id for ttl.
+	Remember(ctx context.Context, id string, ttl time.Duration)
+}
+
+// ReplayReserver is implemented by ReplayStores that can check and
+// remember an id in one atomic step, such as a Redis store using SET NX. A
+// Verifier uses it to reserve each delivery, so that concurrent copies of
+// it cannot all be accepted. With stores that do not implement it, the
+// Verifier calls Seen and then Remember, and copies arriving within the
+// store's round-trip time of each other may all be accepted.
+// MemoryReplayStore implements it.
+type ReplayReserver interface {
+	// Reserve remembers id for ttl and reports true, unless id is already
+	// remembered and has not expired, in which case it reports false and
+	// leaves it as is.
+	Reserve(ctx context.Context, id string, ttl time.Duration) bool
+}
+
+// reserve reserves id in store for ttl, atomically if store implements
+// ReplayReserver, and reports whether it was not remembered yet.
+func reserve(ctx context.Context, store ReplayStore, id string, ttl time.Duration) bool {
+	if r, ok := store.(ReplayReserver); ok {
+		return r.Reserve(ctx, id, ttl)
+	}
+	if store.Seen(ctx, id) {
+		return false
+	}
+	store.Remember(ctx, id, ttl)
+	return true
+}
+
+// ReplayForgetter is implemented by ReplayStores that can forget an id
+// before it expires. A Verifier uses it to release deliveries whose
+// handling failed, so that the sender's retry is processed rather than
+// rejected as a replay; with stores that do not implement it, such
+// deliveries are rejected until they expire. MemoryReplayStore implements
+// it.
+type ReplayForgetter interface {
+	// Forget removes id, if it is remembered.
+	Forget(ctx context.Context, id string)
+}
+
+// Pinger is implemented by stores that can report whether their backend is
+// reachable, such as a ReplayStore backed by Redis. Verifier.Ready calls it.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// deliveryID identifies a delivery by its timestamp and the verified
+// signature bytes returned by signatureCheck.match. Headers that are not
+// signed, such as the delivery id, and the signature header as sent are
+// left out, since a replay could change them.
+func deliveryID(tsStr string, mac []byte) string {
+	sum := sha256.Sum256([]byte(tsStr + "." + hex.EncodeToString(mac)))
+	return hex.EncodeToString(sum[:])
+}
+
+type deliveryKey struct{}
+
+// delivery is what the replay check learned about the delivery of a
+// request, kept in its context by AttachRequestID so that the delivery can
+// be released, or its response cached under the same id, once the request
+// is handled.
+type delivery struct {
+	id       string // deliveryID, once the signature is verified
+	reserved bool   // id was remembered by this request
+	payload  string // payloadID remembered by this request, if any
+	// compressed is the gzip body as signed, if it was signed compressed,
+	// so that a Forwarder can pass on the bytes the signature covers.
+	compressed []byte
+}
+
+// deliveryFromContext returns the delivery carried by ctx, or nil.
+func deliveryFromContext(ctx context.Context) *delivery {
+	d, _ := ctx.Value(deliveryKey{}).(*delivery)
+	return d
+}
+
+// payloadID identifies a delivery by the SHA-256 of its body. The prefix
+// keeps payload ids apart from delivery ids when both share a store.
+func payloadID(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "payload:" + hex.EncodeToString(sum[:])
+}
+
+// MemoryReplayStore is an in-memory ReplayStore. Expired entries are evicted
+// periodically in the background until Close is called.
+type MemoryReplayStore struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewMemoryReplayStore returns a MemoryReplayStore that evicts expired
+// entries every evictInterval.
+func NewMemoryReplayStore(evictInterval time.Duration) *MemoryReplayStore {
+	s := &MemoryReplayStore{
+		entries: make(map[string]time.Time),
+		done:    make(chan struct{}),
+	}
+	go s.evictLoop(evictInterval)
+	return s
+}
+
+// Seen implements ReplayStore.
+func (s *MemoryReplayStore) Seen(_ context.Context, id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry, ok := s.entries[id]
+	return ok && time.Now().Before(expiry)
+}
+
+// Remember implements ReplayStore.
+func (s *MemoryReplayStore) Remember(_ context.Context, id string, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[id] = time.Now().Add(ttl)
+}
+
+// Reserve implements ReplayReserver.
+func (s *MemoryReplayStore) Reserve(_ context.Context, id string, ttl time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if expiry, ok := s.entries[id]; ok && now.Before(expiry) {
+		return false
+	}
+	s.entries[id] = now.Add(ttl)
+	return true
+}
+
+// Forget implements ReplayForgetter.
+func (s *MemoryReplayStore) Forget(_ context.Context, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, id)
+}
+
+// Close stops the background eviction.
+func (s *MemoryReplayStore) Close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+func (s *MemoryReplayStore) evictLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.evict(time.Now())
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *MemoryReplayStore) evict(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, expiry := range s.entries {
+		if !now.Before(expiry) {
+			delete(s.entries, id)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	requestIDHeader = "x-request-id"
+	// maxRequestIDLength bounds ids taken from request headers, which are
+	// set by the sender and end up in every log line.
+	maxRequestIDLength = 128
+)
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the correlation id
+// id. It is mostly useful when processing events outside of a request;
+// WorkerPool does it for each event.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the correlation id carried by ctx, or "" if
+// there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// AttachRequestID returns r with a correlation id in its context, so that
+// the logs written while verifying, dispatching and processing the webhook
+// can be tied together. The id is taken from the "x-delivery-id" header
+// if the sender sets it, so the logs line up with OpenVidu Meet's delivery
+// logs, then from the "x-request-id" header, and is otherwise generated.
+// r is returned unchanged if it already has an id.
+//
+// The context also keeps track of the delivery as it is verified, which
+// Verifier.CachedResponse and Verifier.CacheResponse rely on. The handlers
+// and middlewares in this module call it before reading the webhook, and
+// Verifier.ReadRequest when they are not used; handlers written against
+// ReadRequest that use WithResponseCache must call it first and keep using
+// the request it returns.
+func AttachRequestID(r *http.Request) *http.Request {
+	ctx := r.Context()
+	if deliveryFromContext(ctx) == nil {
+		ctx = context.WithValue(ctx, deliveryKey{}, &delivery{})
+	}
+	if RequestIDFromContext(ctx) == "" {
+		id := ""
+		for _, header := range []string{deliveryIDHeader, requestIDHeader} {
+			if v := r.Header.Get(header); v != "" && len(v) <= maxRequestIDLength {
+				id = v
+				break
+			}
+		}
+		if id == "" {
+			id = rand.Text()
+		}
+		ctx = ContextWithRequestID(ctx, id)
+	}
+	if ctx == r.Context() {
+		return r
+	}
+	return r.WithContext(ctx)
+}
+
+// NewRequestIDHandler returns a slog.Handler that adds the correlation id
+// found in the context, if any, as a "request_id" attribute to every record
+// before passing it to h. Only records logged with a context, such as with
+// Logger.InfoContext, can carry it. The loggers given to WithLogger,
+// WithQueueLogger, Dispatcher.SetLogger and WithForwardLogger are wrapped
+// with it; applications can use it for their own handler logs.
+func NewRequestIDHandler(h slog.Handler) slog.Handler {
+	if _, ok := h.(requestIDHandler); ok {
+		return h
+	}
+	return requestIDHandler{h}
+}
+
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
+
+// withRequestIDs returns logger with its handler wrapped by
+// NewRequestIDHandler.
+func withRequestIDs(logger *slog.Logger) *slog.Logger {
+	return slog.New(NewRequestIDHandler(logger.Handler()))
+}
@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAttachRequestID(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"delivery id preferred", map[string]string{"x-delivery-id": "delivery-1", "x-request-id": "request-1"}, "delivery-1"},
+		{"request id", map[string]string{"x-request-id": "request-1"}, "request-1"},
+		{"generated", nil, ""},
+		{"overlong header ignored", map[string]string{"x-request-id": strings.Repeat("a", maxRequestIDLength+1)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(nil, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			id := RequestIDFromContext(AttachRequestID(req).Context())
+			switch {
+			case tt.want != "" && id != tt.want:
+				t.Errorf("request id = %q, want %q", id, tt.want)
+			case tt.want == "" && (id == "" || len(id) > maxRequestIDLength):
+				t.Errorf("request id = %q, want a generated id", id)
+			}
+		})
+	}
+
+	req := AttachRequestID(newRequest(nil, nil))
+	if again := AttachRequestID(req); again != req {
+		t.Error("AttachRequestID replaced an existing id")
+	}
+}
+
+func TestRequestIDCorrelation(t *testing.T) {
+	body := []byte(testBody)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	v := mustVerifier(t, testKey, WithLogger(logger))
+
+	var got *WebhookEvent
+	h := Handler(v, func(w http.ResponseWriter, r *http.Request, event *WebhookEvent) {
+		got = event
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := newRequest(body, signedHeaders(testKey, body, time.Now()))
+	req.Header.Set("x-delivery-id", "delivery-1")
+	serve(h, req)
+	if got == nil || got.RequestID != "delivery-1" {
+		t.Fatalf("event = %+v, want request id delivery-1", got)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log output %q is not JSON: %v", buf.String(), err)
+	}
+	if entry["request_id"] != "delivery-1" {
+		t.Errorf("log entry = %v, want request_id delivery-1", entry)
+	}
+
+	req = newRequest(body, signedHeaders("wrong-key", body, time.Now()))
+	req.Header.Set("x-request-id", "request-1")
+	rec := serve(h, req)
+	var resp map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp["request_id"] != "request-1" || resp["error"] == "" {
+		t.Errorf("error response = %v, want request_id request-1", resp)
+	}
+}
+
+func TestWorkerPoolRequestID(t *testing.T) {
+	pool := NewWorkerPool(1, 10)
+	ids := make(chan string, 1)
+	pool.Handle(func(ctx context.Context, event *WebhookEvent) error {
+		ids <- RequestIDFromContext(ctx)
+		return nil
+	})
+	pool.Submit(context.Background(), &WebhookEvent{Type: EventMeetingStarted, RequestID: "delivery-1"})
+	pool.Close(context.Background())
+
+	if id := <-ids; id != "delivery-1" {
+		t.Errorf("handler context request id = %q, want delivery-1", id)
+	}
+}
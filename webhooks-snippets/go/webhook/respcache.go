@@ -0,0 +1,283 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CachedResponse is the response to a successfully handled delivery, kept
+// by a ResponseCache to answer its retries.
+type CachedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Write writes resp to w.
+func (resp *CachedResponse) Write(w http.ResponseWriter) {
+	for name, values := range resp.Header {
+		w.Header()[name] = slices.Clone(values)
+	}
+	if resp.Body != nil {
+		w.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+	}
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// ResponseCache stores the responses to handled deliveries by the id the
+// replay check knows them by.
+// See WithResponseCache. Implementations must be safe for concurrent use.
+type ResponseCache interface {
+	// Get returns the response stored for id, if it has not expired.
+	Get(ctx context.Context, id string) (*CachedResponse, bool)
+	// Put stores resp for id for ttl.
+	Put(ctx context.Context, id string, resp *CachedResponse, ttl time.Duration)
+}
+
+// WithResponseCache makes retries of a delivery that was already handled
+// get the response it was handled with, instead of being handled again, so
+// a sender that timed out waiting for the first response can retry safely.
+// Responses are stored in cache under the id the replay check knows the
+// delivery by for as long as the replay check remembers it, and only for
+// deliveries whose handling succeeded with a 2xx status.
+//
+// A retry is recognized by the replay check, so WithReplayCache is
+// required: when verification fails with ErrReplayDetected and cache holds
+// a response for the delivery, the handlers in this module answer with it.
+// Replays with no stored response, because handling is still in progress,
+// are rejected with ErrReplayDetected as before. Either way the replay is
+// logged and counted as a rejection. Deliveries whose handling fails are
+// released, so their retries are handled again, if the replay store
+// implements ReplayForgetter. There is no response cache by default.
+func WithResponseCache(cache ResponseCache) Option {
+	return func(v *Verifier) error {
+		if cache == nil {
+			return errors.New("webhook: response cache must not be nil")
+		}
+		v.responses = cache
+		return nil
+	}
+}
+
+// CachedResponse returns the response stored with WithResponseCache for
+// the delivery r, if verifying r failed with err because it is a retry of
+// a delivery that was handled. It is used by the handlers in this module,
+// and is meant for handlers written against Handler or ReadRequest, which
+// must have passed it r as returned by AttachRequestID.
+func (v *Verifier) CachedResponse(r *http.Request, err error) (*CachedResponse, bool) {
+	d := deliveryFromContext(r.Context())
+	if v.responses == nil || !errors.Is(err, ErrReplayDetected) || d == nil || d.id == "" {
+		return nil, false
+	}
+	return v.responses.Get(r.Context(), d.id)
+}
+
+// CacheResponse stores resp, the response to the successfully handled
+// delivery r, with WithResponseCache. Responses with a status other than
+// 2xx are not stored, nor are responses to requests that were not read
+// as returned by AttachRequestID.
+func (v *Verifier) CacheResponse(r *http.Request, resp *CachedResponse) {
+	d := deliveryFromContext(r.Context())
+	if v.responses == nil || resp.Status < 200 || resp.Status > 299 || d == nil || d.id == "" {
+		return
+	}
+	v.responses.Put(r.Context(), d.id, resp, v.maxAge+v.clockSkew)
+}
+
+// Complete reports that the delivery r was answered with resp, once it has
+// been handled. A 2xx response confirms the delivery: it stays remembered
+// by the replay and dedupe stores, and resp is stored with
+// WithResponseCache. Any other response releases the delivery with
+// Release, so that the sender's retry is processed again. A status of 0,
+// as recorded when nothing was written, counts as 200, which net/http sends
+// in that case. It is used by the handlers in this module, and is meant for
+// handlers written against ReadRequest.
+func (v *Verifier) Complete(r *http.Request, resp *CachedResponse) {
+	if resp.Status == 0 {
+		resp.Status = http.StatusOK
+	}
+	if resp.Status < 200 || resp.Status > 299 {
+		v.Release(r)
+		return
+	}
+	v.CacheResponse(r, resp)
+}
+
+// Release forgets the delivery r in the replay and dedupe stores, if it was
+// recorded by reading r, so that a retry of a delivery whose handling
+// failed is not rejected with ErrReplayDetected or ErrDuplicateEvent. Only
+// stores that implement ReplayForgetter can forget it; with others the
+// delivery stays recorded until it expires. ReadRequest releases the
+// deliveries it rejects itself; r must be the request returned by
+// AttachRequestID.
+func (v *Verifier) Release(r *http.Request) {
+	d := deliveryFromContext(r.Context())
+	if d == nil {
+		return
+	}
+	// The request may have been canceled, which is why it failed.
+	ctx := context.WithoutCancel(r.Context())
+	if f, ok := v.replay.(ReplayForgetter); ok && d.reserved {
+		f.Forget(ctx, d.id)
+	}
+	if f, ok := v.dedupe.(ReplayForgetter); ok && d.payload != "" {
+		f.Forget(ctx, d.payload)
+	}
+	d.reserved, d.payload = false, ""
+}
+
+// tracksDeliveries reports whether handlers must tell v how deliveries were
+// answered, with Complete.
+func (v *Verifier) tracksDeliveries() bool {
+	return v.replay != nil || v.dedupe != nil || v.responses != nil
+}
+
+// serveDelivery calls next to answer the verified delivery r, recording
+// the response for Complete if v needs it.
+func (v *Verifier) serveDelivery(w http.ResponseWriter, r *http.Request, next func(http.ResponseWriter)) {
+	if !v.tracksDeliveries() {
+		next(w)
+		return
+	}
+	rec := NewResponseRecorder(w)
+	next(rec)
+	v.Complete(r, rec.Response())
+}
+
+// ResponseCache returns the cache set with WithResponseCache, or nil if
+// there is none.
+func (v *Verifier) ResponseCache() ResponseCache {
+	return v.responses
+}
+
+// ResponseRecorder is an http.ResponseWriter that passes everything through
+// to the wrapped writer while recording the response, so it can be stored
+// with Verifier.CacheResponse.
+type ResponseRecorder struct {
+	http.ResponseWriter
+
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+// NewResponseRecorder returns a ResponseRecorder writing to w.
+func NewResponseRecorder(w http.ResponseWriter) *ResponseRecorder {
+	return &ResponseRecorder{ResponseWriter: w}
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (rec *ResponseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+		rec.header = rec.Header().Clone()
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (rec *ResponseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (rec *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Response returns the response recorded so far. Its status is 0 if
+// nothing was written.
+func (rec *ResponseRecorder) Response() *CachedResponse {
+	resp := &CachedResponse{Status: rec.status, Header: rec.header}
+	if rec.body.Len() > 0 {
+		resp.Body = bytes.Clone(rec.body.Bytes())
+	}
+	resp.Header.Del("Content-Length")
+	return resp
+}
+
+// MemoryResponseCache is an in-memory ResponseCache. Expired responses are
+// evicted periodically in the background until Close is called.
+type MemoryResponseCache struct {
+	mu        sync.Mutex
+	responses map[string]cachedEntry
+	done      chan struct{}
+	once      sync.Once
+}
+
+type cachedEntry struct {
+	resp   *CachedResponse
+	expiry time.Time
+}
+
+// NewMemoryResponseCache returns a MemoryResponseCache that evicts expired
+// responses every evictInterval.
+func NewMemoryResponseCache(evictInterval time.Duration) *MemoryResponseCache {
+	c := &MemoryResponseCache{
+		responses: make(map[string]cachedEntry),
+		done:      make(chan struct{}),
+	}
+	go c.evictLoop(evictInterval)
+	return c
+}
+
+// Get implements ResponseCache.
+func (c *MemoryResponseCache) Get(_ context.Context, id string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.responses[id]
+	if !ok || !time.Now().Before(entry.expiry) {
+		return nil, false
+	}
+	return entry.resp, true
+}
+
+// Put implements ResponseCache.
+func (c *MemoryResponseCache) Put(_ context.Context, id string, resp *CachedResponse, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.responses[id] = cachedEntry{resp: resp, expiry: time.Now().Add(ttl)}
+}
+
+// Close stops the background eviction.
+func (c *MemoryResponseCache) Close() {
+	c.once.Do(func() { close(c.done) })
+}
+
+func (c *MemoryResponseCache) evictLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.evict(time.Now())
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *MemoryResponseCache) evict(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, entry := range c.responses {
+		if !now.Before(entry.expiry) {
+			delete(c.responses, id)
+		}
+	}
+}
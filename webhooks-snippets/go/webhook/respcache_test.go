@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDispatchHandlerResponseCache(t *testing.T) {
+	body := []byte(testBody)
+	replay := NewMemoryReplayStore(time.Minute)
+	defer replay.Close()
+	responses := NewMemoryResponseCache(time.Minute)
+	defer responses.Close()
+	v := mustVerifier(t, testKey,
+		WithReplayCache(replay),
+		WithResponseCache(responses),
+		WithSuccessResponse([]byte(`{"ack":true}`), "application/json"),
+	)
+
+	var calls int
+	fail := false
+	h := DispatchHandler(v, func(ctx context.Context, event *WebhookEvent) error {
+		calls++
+		if fail {
+			return errors.New("database unavailable")
+		}
+		return nil
+	})
+
+	headers := signedHeaders(testKey, body, time.Now())
+	headers.Set("x-delivery-id", "delivery-1")
+	for i := range 2 {
+		rec := serve(h, newRequest(body, headers))
+		if rec.Code != http.StatusOK || rec.Body.String() != `{"ack":true}` || rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("delivery %d: response = %d %q, want the acknowledgment", i, rec.Code, rec.Body)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+
+	// Failed deliveries are not cached but released, so their retries are
+	// processed again.
+	fail = true
+	headers = signedHeaders(testKey, body, time.Now().Add(-time.Second))
+	headers.Set("x-delivery-id", "delivery-2")
+	if rec := serve(h, newRequest(body, headers)); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("failed delivery: status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	fail = false
+	if rec := serve(h, newRequest(body, headers)); rec.Code != http.StatusOK || calls != 3 {
+		t.Errorf("retry of failed delivery: status = %d after %d handler calls, want %d after 3", rec.Code, calls, http.StatusOK)
+	}
+	if rec := serve(h, newRequest(body, headers)); rec.Code != http.StatusOK || calls != 3 {
+		t.Errorf("second retry: status = %d after %d handler calls, want the cached %d", rec.Code, calls, http.StatusOK)
+	}
+}
+
+func TestReplayReleasedAfterFailure(t *testing.T) {
+	body := []byte(testBody)
+	replay := NewMemoryReplayStore(time.Minute)
+	defer replay.Close()
+	dedupe := NewMemoryReplayStore(time.Minute)
+	defer dedupe.Close()
+	v := mustVerifier(t, testKey, WithReplayCache(replay), WithDedupeByPayload(dedupe, time.Hour))
+
+	var calls int
+	h := DispatchHandler(v, func(ctx context.Context, event *WebhookEvent) error {
+		calls++
+		if calls == 1 {
+			return ErrQueueFull
+		}
+		return nil
+	})
+	headers := signedHeaders(testKey, body, time.Now())
+	if rec := serve(h, newRequest(body, headers)); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("first delivery: status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if rec := serve(h, newRequest(body, headers)); rec.Code != http.StatusOK {
+		t.Errorf("retry: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := serve(h, newRequest(body, headers)); rec.Code != StatusCode(ErrReplayDetected) {
+		t.Errorf("replay of handled delivery: status = %d, want %d", rec.Code, StatusCode(ErrReplayDetected))
+	}
+}
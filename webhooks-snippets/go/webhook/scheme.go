@@ -0,0 +1,196 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+	"time"
+)
+
+// SignatureScheme is how webhook signatures are computed and checked.
+type SignatureScheme int
+
+const (
+	// SchemeHMAC is an HMAC of the signed payload with a shared API key,
+	// using the hash set with WithHashAlgorithm. OpenVidu Meet signs
+	// webhooks this way, so this is the default.
+	SchemeHMAC SignatureScheme = iota
+	// SchemeEd25519 is an Ed25519 signature of the signed payload. The
+	// sender holds the private key and the Verifier only the public key,
+	// so a compromised receiver cannot forge webhooks. Keys are given to
+	// the Verifier as base64-encoded 32-byte public keys.
+	SchemeEd25519
+)
+
+func (s SignatureScheme) String() string {
+	switch s {
+	case SchemeHMAC:
+		return "hmac"
+	case SchemeEd25519:
+		return "ed25519"
+	default:
+		return fmt.Sprintf("SignatureScheme(%d)", int(s))
+	}
+}
+
+// SignEd25519 is like Sign but signs with an Ed25519 private key, for
+// webhooks verified with SchemeEd25519:
+//
+//	signature = hex(Ed25519(key, timestamp + "." + body))
+func SignEd25519(key ed25519.PrivateKey, body []byte, ts time.Time) (signature string, timestamp string) {
+	timestamp = UnitMilliseconds.format(ts)
+	message := append([]byte(signedPrefix(timestamp, "")), body...)
+	return EncodingHex.encode(ed25519.Sign(key, message)), timestamp
+}
+
+// parsePublicKey decodes a base64-encoded Ed25519 public key.
+func parsePublicKey(key string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: invalid Ed25519 public key: %w", err)
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("webhook: invalid Ed25519 public key: got %d bytes, want %d", len(b), ed25519.PublicKeySize)
+	}
+	return b, nil
+}
+
+// algorithm returns the name signers may put before a signature, as in
+// "sha256=...".
+func (v *Verifier) algorithm() string {
+	if v.scheme == SchemeEd25519 {
+		return SchemeEd25519.String()
+	}
+	return v.hash.String()
+}
+
+// signatureCheck checks the signatures of a webhook against every key. The
+// body is written to it as it is read, and match is called once the whole
+// body has been read.
+type signatureCheck interface {
+	io.Writer
+	// match returns the index of the first key that produced one of
+	// signatures, or -1, and the bytes the replay check identifies the
+	// webhook by, which depend only on what is signed and not on how the
+	// signatures were sent.
+	match(body []byte, signatures [][]byte) (int, []byte)
+}
+
+// keySet holds the keys a webhook may be signed with and, for the fixed
+// keys of an HMAC Verifier, a pool of keyed HMACs per key, so verifying a
+// webhook does not set up a new HMAC for each key.
+type keySet struct {
+	keys [][]byte
+	macs []*sync.Pool // nil for keys from a KeyProvider
+}
+
+// newKeySet returns the keySet of v's fixed keys.
+func (v *Verifier) newKeySet(keys [][]byte) *keySet {
+	set := &keySet{keys: keys}
+	if v.scheme != SchemeHMAC {
+		return set
+	}
+	set.macs = make([]*sync.Pool, len(keys))
+	for i, key := range keys {
+		set.macs[i] = &sync.Pool{New: func() any { return hmac.New(v.hash.new(), key) }}
+	}
+	return set
+}
+
+// newCheck returns a signatureCheck for v's scheme and the keys in set,
+// with prefix, the part of the signed payload before the body, already
+// written.
+func (v *Verifier) newCheck(set *keySet, prefix string) signatureCheck {
+	if v.scheme == SchemeEd25519 {
+		return &ed25519Check{keys: set.keys, prefix: prefix}
+	}
+
+	c := &hmacCheck{macs: make([]hash.Hash, len(set.keys)), pools: set.macs}
+	for i, key := range set.keys {
+		if c.pools != nil {
+			c.macs[i] = c.pools[i].Get().(hash.Hash)
+		} else {
+			c.macs[i] = hmac.New(v.hash.new(), key)
+		}
+		io.WriteString(c.macs[i], prefix)
+	}
+	return c
+}
+
+// hmacCheck holds one HMAC per key, taken from pools if there are any.
+type hmacCheck struct {
+	macs  []hash.Hash
+	pools []*sync.Pool
+}
+
+func (c *hmacCheck) Write(p []byte) (int, error) {
+	for _, mac := range c.macs {
+		mac.Write(p)
+	}
+	return len(p), nil
+}
+
+// match also returns the HMACs to their pools, so c must not be used
+// afterwards. Checks abandoned before match leave theirs to the garbage
+// collector. The webhook is identified by its HMAC with the first key,
+// so that resending it with only some of the signatures sent during a key
+// rotation, or with them encoded differently, is still recognized.
+func (c *hmacCheck) match(_ []byte, signatures [][]byte) (int, []byte) {
+	// Every key and signature pair is checked so the time taken does not
+	// reveal which one matched.
+	matched := -1
+	var id []byte
+	var buf [64]byte // large enough for SHA-512
+	for i, mac := range c.macs {
+		expected := mac.Sum(buf[:0])
+		if i == 0 {
+			id = bytes.Clone(expected)
+		}
+		for _, actual := range signatures {
+			if subtle.ConstantTimeCompare(expected, actual) == 1 && matched == -1 {
+				matched = i
+			}
+		}
+		if c.pools != nil {
+			mac.Reset()
+			c.pools[i].Put(mac)
+		}
+	}
+	return matched, id
+}
+
+// ed25519Check verifies against the public keys once the body is known.
+// Ed25519 cannot be computed incrementally, so writes are ignored.
+type ed25519Check struct {
+	keys   [][]byte
+	prefix string
+}
+
+func (c *ed25519Check) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// match identifies the webhook by the SHA-256 of the signed message, since
+// each key signs it differently.
+func (c *ed25519Check) match(body []byte, signatures [][]byte) (int, []byte) {
+	message := append([]byte(c.prefix), body...)
+	for i, key := range c.keys {
+		if len(key) != ed25519.PublicKeySize {
+			continue // from a KeyProvider; ed25519.Verify would panic
+		}
+		for _, sig := range signatures {
+			if ed25519.Verify(key, message, sig) {
+				sum := sha256.Sum256(message)
+				return i, sum[:]
+			}
+		}
+	}
+	return -1, nil
+}
@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Sign signs body the way OpenVidu Meet does and returns the values to send
+// in the signature and timestamp headers. It is the reference
+// implementation of the signing scheme:
+//
+//	timestamp = milliseconds since epoch of ts, in decimal
+//	signature = hex(HMAC-SHA256(apiKey, timestamp + "." + body))
+func Sign(apiKey string, body []byte, ts time.Time) (signature string, timestamp string) {
+	return SignVersion(apiKey, body, ts, "")
+}
+
+// SignVersion is like Sign for a webhook sent with version in the
+// "x-webhook-version" header. The version is covered by the signature so
+// it cannot be changed in transit:
+//
+//	signature = hex(HMAC-SHA256(apiKey, timestamp + "." + version + "." + body))
+//
+// An empty version signs exactly like Sign.
+func SignVersion(apiKey string, body []byte, ts time.Time, version string) (signature string, timestamp string) {
+	timestamp = UnitMilliseconds.format(ts)
+	return EncodingHex.encode(computeMAC(HashSHA256, []byte(apiKey), signedPrefix(timestamp, version), body)), timestamp
+}
+
+// Sign is like the package-level Sign but uses v's primary key, hash
+// algorithm, signature encoding and timestamp unit, so the result is
+// accepted by v. With SchemeEd25519 v only holds public keys and cannot
+// sign, and with a KeyProvider it has no primary key, so the signature is
+// empty. With WithBodyCanonicalizer the canonical form of body is signed,
+// and the signature is empty if body cannot be canonicalized.
+func (v *Verifier) Sign(body []byte, ts time.Time) (signature string, timestamp string) {
+	timestamp = v.timestampUnit.format(ts)
+	signed, err := v.canonicalBody(body)
+	if v.scheme == SchemeEd25519 || v.keyProvider != nil || err != nil {
+		return "", timestamp
+	}
+	return v.encoding.encode(computeMAC(v.hash, v.primaryKey(), signedPrefix(timestamp, ""), signed)), timestamp
+}
+
+// signedPrefix returns what precedes the body in the signed payload.
+func signedPrefix(timestamp, version string) string {
+	if version == "" {
+		return timestamp + "."
+	}
+	return timestamp + "." + version + "."
+}
+
+func computeMAC(alg HashAlgorithm, key []byte, prefix string, body []byte) []byte {
+	mac := hmac.New(alg.new(), key)
+	io.WriteString(mac, prefix)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// ExpectedSignature returns the signature v expects for body sent with the
+// timestamp and version in headers, computed with v's primary key or the
+// first key the KeyProvider returns for headers. It is meant for debugging
+// rejected webhooks. With SchemeEd25519 there is no signature to compare
+// against, and for an unknown tenant no key, so it returns "".
+func (v *Verifier) ExpectedSignature(headers http.Header, body []byte) string {
+	if v.scheme == SchemeEd25519 {
+		return ""
+	}
+	keys, err := v.requestKeys(headers)
+	if err != nil {
+		return ""
+	}
+	signed, err := v.canonicalBody(body)
+	if err != nil {
+		return ""
+	}
+	prefix := signedPrefix(headers.Get(v.timestampHeader), headers.Get(versionHeader))
+	return v.encoding.encode(computeMAC(v.hash, keys.keys[0], prefix, signed))
+}
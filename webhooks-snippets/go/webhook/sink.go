@@ -0,0 +1,269 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// EventSink durably records verified webhook events, for example for
+// auditing. Verifier.ReadRequest calls Store for every event that passes
+// verification and parsing; see WithEventSink. Implementations must be
+// safe for concurrent use.
+type EventSink interface {
+	// Store records event, along with the raw body it was parsed from.
+	Store(ctx context.Context, event *WebhookEvent, raw []byte) error
+}
+
+// SinkFailureMode decides what happens to a webhook when its EventSink
+// fails to store it.
+type SinkFailureMode int
+
+const (
+	// SinkFailureLog logs the failure and accepts the webhook anyway.
+	SinkFailureLog SinkFailureMode = iota
+	// SinkFailureReject rejects the webhook with ErrSinkFailed so the
+	// sender retries it later.
+	SinkFailureReject
+)
+
+func (m SinkFailureMode) String() string {
+	switch m {
+	case SinkFailureLog:
+		return "log"
+	case SinkFailureReject:
+		return "reject"
+	default:
+		return fmt.Sprintf("SinkFailureMode(%d)", int(m))
+	}
+}
+
+const (
+	// RejectionBodyLimit is how much of a rejected webhook's body is kept
+	// in a RejectedWebhook.
+	RejectionBodyLimit = 8 << 10 // 8 KiB
+	// RejectionHeaderLimit is how many header values of a rejected webhook
+	// are kept in a RejectedWebhook, and RejectionHeaderValueLimit how many
+	// bytes of each.
+	RejectionHeaderLimit      = 32
+	RejectionHeaderValueLimit = 1 << 10 // 1 KiB
+
+	// DefaultRejectionSinkLimit is how many rejected webhooks of each
+	// reason are stored per DefaultRejectionSinkWindow by default. See
+	// WithRejectionSinkSampling.
+	DefaultRejectionSinkLimit  = 100
+	DefaultRejectionSinkWindow = time.Minute
+)
+
+// RejectionSink records webhooks that were rejected, so forgeries and
+// format regressions can be investigated after the fact. See
+// WithRejectionSink. Implementations must be safe for concurrent use.
+type RejectionSink interface {
+	StoreRejected(ctx context.Context, rejected RejectedWebhook) error
+}
+
+// RejectedWebhook is a rejected webhook request, as passed to a
+// RejectionSink.
+type RejectedWebhook struct {
+	ReceivedAt time.Time `json:"receivedAt"`
+	// Reason is Reason(err) for the rejection error, and Error its message.
+	Reason     string `json:"reason"`
+	Error      string `json:"error"`
+	RemoteAddr string `json:"remoteAddr"`
+	// Headers are the request headers, without credentials such as
+	// Authorization and Cookie. The signature is redacted unless enabled
+	// with WithRejectionSignatures. At most RejectionHeaderLimit values are
+	// kept, by header name order, each cut to RejectionHeaderValueLimit
+	// bytes, and HeadersTruncated reports whether anything was left out.
+	Headers          http.Header `json:"headers"`
+	HeadersTruncated bool        `json:"headersTruncated"`
+	// Body holds up to RejectionBodyLimit bytes of the body, and Truncated
+	// reports whether there were more. Body is empty for requests rejected
+	// before their body was read, such as those with missing headers or a
+	// disallowed address.
+	Body      []byte `json:"body"`
+	Truncated bool   `json:"truncated"`
+}
+
+// redactedValue replaces the signature in RejectedWebhook.Headers.
+const redactedValue = "[redacted]"
+
+// credentialHeaders are never passed to a RejectionSink.
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+
+// storeRejected passes a webhook rejected with err to the rejection sink,
+// if any and within its budget. Rate-limited requests are not stored,
+// since the rate limit is what keeps a flood of them from reaching the
+// sink.
+func (v *Verifier) storeRejected(r *http.Request, body []byte, err error) {
+	if v.rejectionSink == nil || errors.Is(err, ErrRateLimited) {
+		return
+	}
+	reason, now := Reason(err), v.clock.Now()
+	store, skipped, since := v.sinkSampler.sample(reason, now)
+	if skipped > 0 {
+		v.logSkipped(r.Context(), sampleSummary{reason: reason, suppressed: skipped, since: since})
+	}
+	if !store {
+		return
+	}
+
+	headers := r.Header.Clone()
+	for _, name := range credentialHeaders {
+		headers.Del(name)
+	}
+	if !v.keepSignatures && headers.Get(v.signatureHeader) != "" {
+		headers.Set(v.signatureHeader, redactedValue)
+	}
+	headers, headersTruncated := boundHeaders(headers)
+	rejected := RejectedWebhook{
+		ReceivedAt:       now,
+		Reason:           reason,
+		Error:            err.Error(),
+		RemoteAddr:       v.clientIP(r),
+		Headers:          headers,
+		HeadersTruncated: headersTruncated,
+		Body:             slices.Clone(body[:min(len(body), RejectionBodyLimit)]),
+		Truncated:        len(body) > RejectionBodyLimit,
+	}
+	if err := v.rejectionSink.StoreRejected(r.Context(), rejected); err != nil {
+		v.logger.ErrorContext(r.Context(), "Failed to store rejected webhook", "error", err)
+	}
+}
+
+// boundHeaders returns the first RejectionHeaderLimit values of headers, by
+// name, each cut to RejectionHeaderValueLimit bytes, and whether anything
+// was left out.
+func boundHeaders(headers http.Header) (http.Header, bool) {
+	bounded := make(http.Header, min(len(headers), RejectionHeaderLimit))
+	var kept int
+	var truncated bool
+	for _, name := range slices.Sorted(maps.Keys(headers)) {
+		for _, value := range headers[name] {
+			if kept == RejectionHeaderLimit {
+				return bounded, true
+			}
+			if len(value) > RejectionHeaderValueLimit {
+				value, truncated = value[:RejectionHeaderValueLimit], true
+			}
+			bounded[name] = append(bounded[name], value)
+			kept++
+		}
+	}
+	return bounded, truncated
+}
+
+// StoredEvent is an event recorded by MemorySink or FileSink.
+type StoredEvent struct {
+	ReceivedAt time.Time       `json:"receivedAt"`
+	Event      EventType       `json:"event"`
+	Raw        json.RawMessage `json:"raw"`
+}
+
+// MemorySink is an EventSink and a RejectionSink that keeps events in
+// memory. It is mostly useful in tests.
+type MemorySink struct {
+	mu       sync.Mutex
+	events   []StoredEvent
+	rejected []RejectedWebhook
+}
+
+// Store implements EventSink.
+func (s *MemorySink) Store(_ context.Context, event *WebhookEvent, raw []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, StoredEvent{
+		ReceivedAt: time.Now(),
+		Event:      event.Type,
+		Raw:        slices.Clone(raw),
+	})
+	return nil
+}
+
+// Events returns the events stored so far, oldest first.
+func (s *MemorySink) Events() []StoredEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.events)
+}
+
+// StoreRejected implements RejectionSink.
+func (s *MemorySink) StoreRejected(_ context.Context, rejected RejectedWebhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rejected = append(s.rejected, rejected)
+	return nil
+}
+
+// Rejected returns the rejected webhooks stored so far, oldest first.
+func (s *MemorySink) Rejected() []RejectedWebhook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.rejected)
+}
+
+// FileSink is an EventSink and a RejectionSink that appends each event to
+// a file as a line of JSON, in the format of StoredEvent or
+// RejectedWebhook. Use separate files for accepted and rejected webhooks.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink returns a FileSink appending to path, which is created if it
+// does not exist.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("webhook: opening event sink: %w", err)
+	}
+	return &FileSink{file: f}, nil
+}
+
+// Store implements EventSink. The line is synced to disk before Store
+// returns.
+func (s *FileSink) Store(_ context.Context, event *WebhookEvent, raw []byte) error {
+	return s.writeLine(StoredEvent{
+		ReceivedAt: time.Now(),
+		Event:      event.Type,
+		Raw:        raw,
+	})
+}
+
+// StoreRejected implements RejectionSink. The line is synced to disk
+// before StoreRejected returns.
+func (s *FileSink) StoreRejected(_ context.Context, rejected RejectedWebhook) error {
+	return s.writeLine(rejected)
+}
+
+func (s *FileSink) writeLine(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(line); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Close closes the underlying file.
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
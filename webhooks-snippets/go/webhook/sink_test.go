@@ -0,0 +1,207 @@
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type failingSink struct{}
+
+func (failingSink) Store(context.Context, *WebhookEvent, []byte) error {
+	return errors.New("disk full")
+}
+
+func TestReadRequestStoresEvent(t *testing.T) {
+	body := []byte(testBody)
+	sink := &MemorySink{}
+	v := mustVerifier(t, testKey, WithEventSink(sink))
+
+	if _, _, err := v.ReadRequest(newRequest(body, signedHeaders(testKey, body, time.Now()))); err != nil {
+		t.Fatalf("ReadRequest() = %v", err)
+	}
+	// Rejected webhooks are not stored.
+	v.ReadRequest(newRequest(body, signedHeaders("wrong-key", body, time.Now())))
+
+	events := sink.Events()
+	if len(events) != 1 {
+		t.Fatalf("stored %d events, want 1", len(events))
+	}
+	if events[0].Event != EventMeetingStarted || string(events[0].Raw) != testBody {
+		t.Errorf("stored event = %+v", events[0])
+	}
+}
+
+func TestReadRequestSinkFailureMode(t *testing.T) {
+	body := []byte(testBody)
+
+	tests := []struct {
+		mode SinkFailureMode
+		want int
+	}{
+		{SinkFailureLog, http.StatusOK},
+		{SinkFailureReject, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			v := mustVerifier(t, testKey, WithEventSink(failingSink{}), WithSinkFailureMode(tt.mode))
+			h := Handler(v, func(w http.ResponseWriter, r *http.Request, event *WebhookEvent) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			rec := serve(h, newRequest(body, signedHeaders(testKey, body, time.Now())))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event, err := ParseEvent([]byte(testBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := sink.Store(context.Background(), event, []byte(testBody)); err != nil {
+			t.Fatalf("Store() = %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var lines int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var stored StoredEvent
+		if err := json.Unmarshal(scanner.Bytes(), &stored); err != nil {
+			t.Fatalf("line %d: %v", lines+1, err)
+		}
+		if stored.Event != EventMeetingStarted {
+			t.Errorf("line %d: event = %q", lines+1, stored.Event)
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("file has %d lines, want 2", lines)
+	}
+}
+
+func TestReadRequestStoresRejected(t *testing.T) {
+	body := []byte(testBody)
+	sink := &MemorySink{}
+	v := mustVerifier(t, testKey, WithRejectionSink(sink))
+
+	forged := newRequest(body, signedHeaders("wrong-key", body, time.Now()))
+	forged.Header.Set("Authorization", "Bearer secret")
+	v.ReadRequest(forged)
+	invalid := []byte(`{"event":"meetingStarted"`)
+	v.ReadRequest(newRequest(invalid, signedHeaders(testKey, invalid, time.Now())))
+	v.ReadRequest(newRequest(body, http.Header{}))
+	v.ReadRequest(newRequest(body, signedHeaders(testKey, body, time.Now())))
+
+	rejected := sink.Rejected()
+	if len(rejected) != 3 {
+		t.Fatalf("stored %d rejections, want 3", len(rejected))
+	}
+	want := []struct {
+		reason string
+		body   string
+	}{
+		{"signature_mismatch", testBody},
+		{"invalid_event", string(invalid)},
+		{"missing_signature", ""},
+	}
+	for i, w := range want {
+		if got := rejected[i]; got.Reason != w.reason || string(got.Body) != w.body {
+			t.Errorf("rejection %d = %s with body %q, want %s with body %q", i, got.Reason, got.Body, w.reason, w.body)
+		}
+	}
+	if h := rejected[0].Headers; h.Get("Authorization") != "" || h.Get("x-signature") != redactedValue || h.Get("x-timestamp") == "" {
+		t.Errorf("stored headers = %v, want no credentials and a redacted signature", h)
+	}
+}
+
+func TestRejectedBodyIsBounded(t *testing.T) {
+	sink := &MemorySink{}
+	v := mustVerifier(t, testKey, WithRejectionSink(sink), WithRejectionSignatures(true))
+
+	body := make([]byte, RejectionBodyLimit+1)
+	headers := signedHeaders("wrong-key", body, time.Now())
+	v.ReadRequest(newRequest(body, headers))
+
+	got := sink.Rejected()[0]
+	if len(got.Body) != RejectionBodyLimit || !got.Truncated {
+		t.Errorf("stored %d bytes, truncated = %t, want %d bytes, truncated", len(got.Body), got.Truncated, RejectionBodyLimit)
+	}
+	if got.Headers.Get("x-signature") != headers.Get("x-signature") {
+		t.Errorf("stored signature = %q, want it kept", got.Headers.Get("x-signature"))
+	}
+}
+
+func TestRejectedHeadersAreBounded(t *testing.T) {
+	body := []byte(testBody)
+	sink := &MemorySink{}
+	v := mustVerifier(t, testKey, WithRejectionSink(sink))
+
+	headers := signedHeaders("wrong-key", body, time.Now())
+	headers.Set("X-Long", strings.Repeat("a", RejectionHeaderValueLimit+1))
+	for i := range RejectionHeaderLimit {
+		headers.Add("X-Padding", strconv.Itoa(i))
+	}
+	v.ReadRequest(newRequest(body, headers))
+
+	got := sink.Rejected()[0]
+	var values int
+	for _, vs := range got.Headers {
+		values += len(vs)
+	}
+	if values != RejectionHeaderLimit || !got.HeadersTruncated {
+		t.Errorf("stored %d header values, truncated = %t, want %d, truncated", values, got.HeadersTruncated, RejectionHeaderLimit)
+	}
+	if long := got.Headers.Get("X-Long"); len(long) != RejectionHeaderValueLimit {
+		t.Errorf("stored %d bytes of a long header, want %d", len(long), RejectionHeaderValueLimit)
+	}
+}
+
+func TestRejectionSinkSampling(t *testing.T) {
+	body := []byte(testBody)
+	sink := &MemorySink{}
+	clock := &fakeClock{now: time.Now()}
+	v := mustVerifier(t, testKey, WithRejectionSink(sink), WithRejectionSinkSampling(2, time.Minute), WithClock(clock))
+
+	for range 5 {
+		v.ReadRequest(newRequest(body, signedHeaders("wrong-key", body, clock.now)))
+	}
+	v.ReadRequest(newRequest(body, http.Header{}))
+	if got := len(sink.Rejected()); got != 3 {
+		t.Errorf("stored %d rejections, want 2 forgeries and 1 missing signature", got)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	v.ReadRequest(newRequest(body, signedHeaders("wrong-key", body, clock.now)))
+	if got := len(sink.Rejected()); got != 4 {
+		t.Errorf("stored %d rejections after the window, want 4", got)
+	}
+}
@@ -0,0 +1,45 @@
+package webhook
+
+import "context"
+
+// EventStream delivers verified webhook events on a channel, for consumers
+// that prefer receiving events to registering handlers.
+//
+// Events are buffered up to the size given to NewEventStream. When the
+// buffer is full the overflow policy applies: with the default PolicyBlock,
+// Send waits for the consumer for up to the block timeout, so a slow
+// consumer delays webhook responses and eventually makes them fail with 503
+// and be retried by the sender, but never loses an acknowledged event. With
+// PolicyDropNewest or PolicyDropOldest the webhook is acknowledged and the
+// offered or the oldest buffered event is discarded, which keeps the HTTP
+// path fast at the cost of losing events; drops are logged and counted
+// with QueueMetrics. PolicyReject503 answers 503 right away.
+type EventStream struct {
+	eventQueue
+}
+
+// NewEventStream returns an EventStream buffering up to bufferSize events.
+func NewEventStream(bufferSize int, opts ...QueueOption) *EventStream {
+	s := &EventStream{}
+	s.init(bufferSize, opts)
+	return s
+}
+
+// Stream returns the channel events are delivered on. It is closed by Close.
+func (s *EventStream) Stream() <-chan *WebhookEvent {
+	return s.ch
+}
+
+// Send delivers event on the stream, applying the overflow policy if the
+// buffer is full. It returns ErrQueueClosed after Close. Send has the
+// HandlerFunc signature, so it can be passed to DispatchHandler.
+func (s *EventStream) Send(ctx context.Context, event *WebhookEvent) error {
+	return s.offer(ctx, event)
+}
+
+// Close stops accepting events and closes the stream channel once the
+// events already buffered have been received. Sends waiting for room in
+// the buffer fail with ErrQueueClosed.
+func (s *EventStream) Close() {
+	s.close()
+}
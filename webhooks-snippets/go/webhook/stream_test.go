@@ -0,0 +1,130 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestEventStream(t *testing.T) {
+	body := []byte(testBody)
+	v := mustVerifier(t, testKey)
+	stream := NewEventStream(1)
+
+	h := DispatchHandler(v, stream.Send)
+	rec := serve(h, newRequest(body, signedHeaders(testKey, body, time.Now())))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	select {
+	case event := <-stream.Stream():
+		if event.Type != EventMeetingStarted {
+			t.Errorf("event type = %s, want %s", event.Type, EventMeetingStarted)
+		}
+	default:
+		t.Fatal("no event on stream")
+	}
+
+	stream.Close()
+	if _, ok := <-stream.Stream(); ok {
+		t.Error("stream not closed after Close")
+	}
+	if err := stream.Send(context.Background(), &WebhookEvent{}); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Send() after Close = %v, want %v", err, ErrQueueClosed)
+	}
+}
+
+type overflowCounter map[OverflowPolicy]int
+
+func (c overflowCounter) IncOverflow(policy OverflowPolicy) { c[policy]++ }
+
+func TestEventStreamBackpressure(t *testing.T) {
+	metrics := overflowCounter{}
+	stream := NewEventStream(1, WithQueueMetrics(metrics))
+	defer stream.Close()
+
+	stream.Send(context.Background(), &WebhookEvent{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := stream.Send(ctx, &WebhookEvent{}); !errors.Is(err, ErrHandlerCanceled) {
+		t.Errorf("Send() on full stream = %v, want %v", err, ErrHandlerCanceled)
+	}
+	if metrics[PolicyBlock] != 1 {
+		t.Errorf("overflows = %v, want one for %s", metrics, PolicyBlock)
+	}
+}
+
+func TestEventStreamDropNewest(t *testing.T) {
+	metrics := overflowCounter{}
+	stream := NewEventStream(1, WithOverflowPolicy(PolicyDropNewest), WithQueueMetrics(metrics))
+	defer stream.Close()
+
+	first := &WebhookEvent{Type: EventMeetingStarted}
+	stream.Send(context.Background(), first)
+	if err := stream.Send(context.Background(), &WebhookEvent{Type: EventMeetingEnded}); err != nil {
+		t.Errorf("Send() on full stream = %v, want nil", err)
+	}
+
+	if got := <-stream.Stream(); got != first {
+		t.Errorf("received %s, want the first event", got.Type)
+	}
+	if metrics[PolicyDropNewest] != 1 {
+		t.Errorf("overflows = %v, want one for %s", metrics, PolicyDropNewest)
+	}
+}
+
+func TestEventStreamDropOldest(t *testing.T) {
+	metrics := overflowCounter{}
+	stream := NewEventStream(1, WithOverflowPolicy(PolicyDropOldest), WithQueueMetrics(metrics))
+	defer stream.Close()
+
+	stream.Send(context.Background(), &WebhookEvent{Type: EventMeetingStarted})
+	second := &WebhookEvent{Type: EventMeetingEnded}
+	if err := stream.Send(context.Background(), second); err != nil {
+		t.Errorf("Send() on full stream = %v, want nil", err)
+	}
+
+	if got := <-stream.Stream(); got != second {
+		t.Errorf("received %s, want the second event", got.Type)
+	}
+	if metrics[PolicyDropOldest] != 1 {
+		t.Errorf("overflows = %v, want one for %s", metrics, PolicyDropOldest)
+	}
+}
+
+func TestEventStreamBlockTimeout(t *testing.T) {
+	stream := NewEventStream(1, WithBlockTimeout(10*time.Millisecond))
+	defer stream.Close()
+
+	stream.Send(context.Background(), &WebhookEvent{})
+	if err := stream.Send(context.Background(), &WebhookEvent{}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Send() on full stream = %v, want %v", err, ErrQueueFull)
+	}
+}
+
+func TestEventStreamCloseWhileBlocked(t *testing.T) {
+	stream := NewEventStream(1, WithBlockTimeout(0))
+	stream.Send(context.Background(), &WebhookEvent{})
+
+	errc := make(chan error, 1)
+	go func() { errc <- stream.Send(context.Background(), &WebhookEvent{}) }()
+	time.Sleep(10 * time.Millisecond) // let the send block
+
+	closed := make(chan struct{})
+	go func() {
+		stream.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked by a waiting send")
+	}
+	if err := <-errc; !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("blocked Send() = %v, want %v", err, ErrQueueClosed)
+	}
+}
@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// TimestampUnit is the unit of the Unix timestamp sent in the timestamp
+// header.
+type TimestampUnit int
+
+const (
+	// UnitMilliseconds is milliseconds since epoch. OpenVidu Meet sends
+	// millisecond timestamps, so this is the default.
+	UnitMilliseconds TimestampUnit = iota
+	// UnitSeconds is seconds since epoch, as used by senders or gateways
+	// that normalize timestamps to Unix seconds.
+	UnitSeconds
+)
+
+func (u TimestampUnit) String() string {
+	switch u {
+	case UnitMilliseconds:
+		return "milliseconds"
+	case UnitSeconds:
+		return "seconds"
+	default:
+		return fmt.Sprintf("TimestampUnit(%d)", int(u))
+	}
+}
+
+func (u TimestampUnit) duration() time.Duration {
+	switch u {
+	case UnitSeconds:
+		return time.Second
+	default:
+		return time.Millisecond
+	}
+}
+
+// since returns t as a number of units since epoch.
+func (u TimestampUnit) since(t time.Time) int64 {
+	switch u {
+	case UnitSeconds:
+		return t.Unix()
+	default:
+		return t.UnixMilli()
+	}
+}
+
+// time converts n units since epoch to a time.Time.
+func (u TimestampUnit) time(n int64) time.Time {
+	switch u {
+	case UnitSeconds:
+		return time.Unix(n, 0)
+	default:
+		return time.UnixMilli(n)
+	}
+}
+
+func (u TimestampUnit) format(t time.Time) string {
+	return strconv.FormatInt(u.since(t), 10)
+}
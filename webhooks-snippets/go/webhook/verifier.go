@@ -0,0 +1,549 @@
+// Package webhook verifies webhook events sent by OpenVidu Meet.
+//
+// OpenVidu Meet signs every webhook with an HMAC-SHA256 of
+// "<timestamp>.<body>" using the project API key, and sends the hex-encoded
+// result in the "x-signature" header along with the timestamp (milliseconds
+// since epoch) in the "x-timestamp" header. Senders that use Unix seconds
+// instead can be accepted with WithTimestampUnit, and senders that sign
+// with an Ed25519 private key with WithSignatureScheme.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Headers used by OpenVidu Meet to send the signature and timestamp of a
+// webhook.
+const (
+	DefaultSignatureHeader = "x-signature"
+	DefaultTimestampHeader = "x-timestamp"
+)
+
+const (
+	deliveryIDHeader = "x-delivery-id"
+	versionHeader    = "x-webhook-version"
+)
+
+const (
+	// DefaultMaxAge is the maximum webhook age accepted unless overridden
+	// with WithMaxAge.
+	DefaultMaxAge = 2 * time.Minute
+	// DefaultClockSkew is how far ahead of the local clock a webhook
+	// timestamp may be unless overridden with WithClockSkew.
+	DefaultClockSkew = 5 * time.Second
+	// DefaultMaxBodySize is the largest webhook body read from a request
+	// unless overridden with WithMaxBodySize.
+	DefaultMaxBodySize = 1 << 20 // 1 MiB
+)
+
+// Verifier checks the signature and age of incoming webhook events.
+type Verifier struct {
+	keys            atomic.Pointer[keySet]
+	additionalKeys  []string // only used until the keys are set by NewVerifier
+	maxAge          time.Duration
+	clockSkew       time.Duration
+	encoding        Encoding
+	hash            HashAlgorithm
+	scheme          SignatureScheme
+	replay          ReplayStore
+	signatureHeader string
+	timestampHeader string
+	maxBodySize     int64
+	contentType     string
+	logger          *slog.Logger
+	logBody         bool
+	quietAccepted   bool
+	logSampler      *rejectionSampler
+	metrics         Metrics
+	verifyMetrics   VerificationMetrics
+	driftMetrics    DriftMetrics
+	onDrift         DriftFunc
+	limiter         *rateLimiter
+	handlerTimeout  time.Duration
+	clock           Clock
+	allowedNets     []netip.Prefix
+	trustedProxies  int
+	timestampUnit   TimestampUnit
+	dedupe          ReplayStore
+	dedupeTTL       time.Duration
+	sink            EventSink
+	sinkFailure     SinkFailureMode
+	validators      []EventValidator
+	gzipSigning     GzipSigning
+	versions        []string
+	deriveKey       func(base []byte) []byte
+	ordering        *orderTracker
+	keyProvider     KeyProvider
+	rejectionSink   RejectionSink
+	sinkSampler     *rejectionSampler
+	keepSignatures  bool
+	lastSeen        lastSeenTable
+	lastSeenMetrics LastSeenMetrics
+	successStatus   int
+	successBody     []byte
+	successType     string
+	errorResponder  ErrorResponder
+	allowedTypes    map[EventType]bool
+	ignoredMetrics  IgnoredMetrics
+	responses       ResponseCache
+	canonicalize    func(body []byte) ([]byte, error)
+	matchTimestamp  bool
+	timestampSlack  time.Duration
+
+	// Background summaries of the rejection samplers, stopped by Close.
+	stop      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// Option configures a Verifier.
+type Option func(*Verifier) error
+
+// NewVerifier returns a Verifier that checks webhooks signed with apiKey.
+// Further keys accepted during a rotation can be added with
+// WithAdditionalKeys. With WithKeyProvider, apiKey must be empty, since
+// the keys are chosen for each webhook by the KeyProvider.
+//
+// Without options the Verifier accepts webhooks the way OpenVidu Meet sends
+// them; each With* option documents its default. Options are applied in
+// order, and NewVerifier fails if any of them is invalid, or if they
+// conflict, such as two options naming the same header.
+func NewVerifier(apiKey string, opts ...Option) (*Verifier, error) {
+	v := &Verifier{
+		maxAge:          DefaultMaxAge,
+		clockSkew:       DefaultClockSkew,
+		encoding:        EncodingHex,
+		hash:            HashSHA256,
+		signatureHeader: DefaultSignatureHeader,
+		timestampHeader: DefaultTimestampHeader,
+		maxBodySize:     DefaultMaxBodySize,
+		logger:          slog.New(slog.DiscardHandler),
+		metrics:         noopMetrics{},
+		clock:           realClock{},
+		successStatus:   http.StatusOK,
+	}
+	for _, opt := range opts {
+		if err := opt(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if v.keyProvider != nil {
+		if apiKey != "" {
+			return nil, errors.New("webhook: api key must be empty with WithKeyProvider")
+		}
+	} else if apiKey == "" {
+		return nil, errors.New("webhook: api key must not be empty")
+	}
+	if err := v.validate(); err != nil {
+		return nil, err
+	}
+	if v.keyProvider == nil {
+		if err := v.SetKeys(append([]string{apiKey}, v.additionalKeys...)); err != nil {
+			return nil, err
+		}
+		v.additionalKeys = nil
+	}
+	v.startSampling()
+	return v, nil
+}
+
+// Close stops v's background work, which only exists with
+// WithRejectionLogSampling or WithRejectionSink, and logs how many
+// rejections were left out in the current sampling windows. v keeps
+// verifying webhooks after Close, but rejections left out from then on are
+// only reported when the next one for the same reason arrives in a new
+// window.
+func (v *Verifier) Close() {
+	v.closeOnce.Do(func() {
+		if v.stop != nil {
+			close(v.stop)
+			<-v.stopped
+		}
+	})
+}
+
+// SetKeys replaces the keys v accepts: keys[0] becomes the primary key and
+// the rest are accepted as additional keys, as with WithAdditionalKeys. It
+// is safe to call while webhooks are being verified, for example to reload
+// keys at runtime; verifications already in progress finish with the keys
+// they started with. Keys are derived with the WithKeyDeriver function, if
+// any. With SchemeEd25519 the keys are base64-encoded public keys. On
+// error the current keys are kept. SetKeys fails if v gets its keys from a
+// KeyProvider.
+func (v *Verifier) SetKeys(keys []string) error {
+	if v.keyProvider != nil {
+		return errors.New("webhook: keys are chosen by the KeyProvider")
+	}
+	if len(keys) == 0 {
+		return errors.New("webhook: at least one api key is required")
+	}
+
+	derived := make([][]byte, len(keys))
+	for i, key := range keys {
+		if key == "" {
+			return errors.New("webhook: api key must not be empty")
+		}
+		if slices.Contains(keys[:i], key) {
+			return errors.New("webhook: the same key is configured twice")
+		}
+		if v.scheme == SchemeEd25519 {
+			pub, err := parsePublicKey(key)
+			if err != nil {
+				return err
+			}
+			derived[i] = pub
+			continue
+		}
+		derived[i] = []byte(key)
+		if v.deriveKey != nil {
+			if derived[i] = v.deriveKey(derived[i]); len(derived[i]) == 0 {
+				return errors.New("webhook: key deriver returned an empty key")
+			}
+		}
+	}
+	v.keys.Store(v.newKeySet(derived))
+	return nil
+}
+
+// primaryKey returns the key webhooks are signed with by Verifier.Sign, or
+// nil if the keys come from a KeyProvider.
+func (v *Verifier) primaryKey() []byte {
+	if keys := v.keys.Load(); keys != nil {
+		return keys.keys[0]
+	}
+	return nil
+}
+
+// Verify checks that body and headers form a valid, recent webhook event.
+// It returns one of the Err* sentinels describing the first failed check.
+// An empty body is rejected with ErrEmptyBody rather than having its
+// signature checked, since no webhook has an empty body.
+//
+// The signature header may hold several comma-separated signatures, as
+// sent during a key rotation; the webhook is accepted if any of them
+// matches any configured key.
+func (v *Verifier) Verify(body []byte, headers http.Header) error {
+	return v.VerifyContext(context.Background(), body, headers)
+}
+
+// VerifyContext is like Verify but passes ctx to the replay store.
+func (v *Verifier) VerifyContext(ctx context.Context, body []byte, headers http.Header) error {
+	_, err := v.VerifyKey(ctx, body, headers)
+	return err
+}
+
+// VerifyKey is like VerifyContext but also reports the index of the key that
+// matched the signature: 0 for the key passed to NewVerifier, followed by
+// the keys added with WithAdditionalKeys in order, or the index among the
+// keys returned by the KeyProvider. It returns -1 when verification fails.
+func (v *Verifier) VerifyKey(ctx context.Context, body []byte, headers http.Header) (int, error) {
+	sig, err := v.parseHeaders(headers)
+	if err != nil {
+		return -1, err
+	}
+	keys, err := v.requestKeys(headers)
+	if err != nil {
+		return -1, err
+	}
+	if len(body) == 0 {
+		return -1, ErrEmptyBody
+	}
+
+	signed, err := v.canonicalBody(body)
+	if err != nil {
+		return -1, err
+	}
+	check := v.newCheck(keys, signedPrefix(sig.timestamp, sig.version))
+	check.Write(signed)
+	return v.finish(ctx, headers, signed, sig, check)
+}
+
+// VerifyAndParse verifies body and headers like Verify and, only once they
+// are verified, parses body with ParseEvent, so that unverified input is
+// never parsed. The returned event has RawBody, RawTimestamp, Timestamp and
+// Age set, and has been checked against the supported versions,
+// WithTimestampMatch and the validators, as ReadRequest would. Unlike
+// ReadRequest it makes no checks on the request itself, and does not
+// record metrics, log or store the event.
+func (v *Verifier) VerifyAndParse(body []byte, headers http.Header) (*WebhookEvent, error) {
+	if err := v.Verify(body, headers); err != nil {
+		return nil, err
+	}
+	event, err := ParseEvent(body)
+	if err != nil {
+		return nil, err
+	}
+	if err := v.checkEvent(event, body, headers); err != nil {
+		return nil, err
+	}
+	event.RawBody = body
+	return event, nil
+}
+
+// VerifyReader is like VerifyContext but reads the body from r. It returns
+// the body so that it can be parsed once verified. The body is read up to
+// the configured maximum before the signature is computed, so larger
+// bodies are rejected with ErrBodyTooLarge without computing it, and it is
+// not read at all if the headers are invalid.
+func (v *Verifier) VerifyReader(ctx context.Context, r io.Reader, headers http.Header) ([]byte, error) {
+	return v.verifyReader(ctx, r, headers, -1)
+}
+
+// verifyReader is VerifyReader for a body expected to be size bytes long,
+// as given by its Content-Length, or of unknown length if size is
+// negative.
+func (v *Verifier) verifyReader(ctx context.Context, r io.Reader, headers http.Header, size int64) ([]byte, error) {
+	sig, keys, err := v.signatureHeaders(headers)
+	if err != nil {
+		return nil, err
+	}
+	return v.verifyBody(ctx, r, headers, size, sig, keys)
+}
+
+// signatureHeaders parses the signature headers and resolves the keys to
+// check them with, so a request can be refused before its body is read.
+func (v *Verifier) signatureHeaders(headers http.Header) (*requestSignature, *keySet, error) {
+	sig, err := v.parseHeaders(headers)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, err := v.requestKeys(headers)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sig, keys, nil
+}
+
+// verifyBody is the rest of verifyReader, once signatureHeaders has
+// accepted the headers.
+func (v *Verifier) verifyBody(ctx context.Context, r io.Reader, headers http.Header, size int64, sig *requestSignature, keys *keySet) ([]byte, error) {
+	if r == nil {
+		return nil, ErrEmptyBody
+	}
+
+	// The body is read within the limit before any of it is signed, so
+	// oversized bodies, even of unknown length, cost no HMAC computation.
+	body, err := readBodySize(r, v.maxBodySize, size)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		return nil, ErrEmptyBody
+	}
+	signed, err := v.canonicalBody(body)
+	if err != nil {
+		return body, err
+	}
+	check := v.newCheck(keys, signedPrefix(sig.timestamp, sig.version))
+	check.Write(signed)
+	if _, err := v.finish(ctx, headers, signed, sig, check); err != nil {
+		return body, err
+	}
+	return body, nil
+}
+
+// canonicalBody returns the bytes the signature of body covers: body
+// itself, or its canonical form with WithBodyCanonicalizer.
+func (v *Verifier) canonicalBody(body []byte) ([]byte, error) {
+	if v.canonicalize == nil {
+		return body, nil
+	}
+	signed, err := v.canonicalize(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: canonicalizing body: %v", ErrInvalidPayload, err)
+	}
+	return signed, nil
+}
+
+// requestSignature holds the signatures and timestamp read from a request.
+type requestSignature struct {
+	signature string // header as sent
+	decoded   [][]byte
+	timestamp string
+	version   string        // "x-webhook-version" header, signed along with the body
+	drift     time.Duration // local time minus timestamp
+}
+
+// supportsVersion reports whether version is allowed by
+// WithSupportedVersions.
+func (v *Verifier) supportsVersion(version string) bool {
+	return v.versions == nil || slices.Contains(v.versions, version)
+}
+
+// parseHeaders reads the signature and timestamp headers and checks the
+// timestamp against the accepted age window.
+func (v *Verifier) parseHeaders(headers http.Header) (*requestSignature, error) {
+	signature := headers.Get(v.signatureHeader)
+	if signature == "" {
+		return nil, ErrMissingSignature
+	}
+	tsStr := headers.Get(v.timestampHeader)
+	if tsStr == "" {
+		return nil, ErrMissingTimestamp
+	}
+
+	timestamp, err := strconv.ParseInt(tsStr, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidTimestamp
+	}
+
+	unit := v.timestampUnit.duration()
+	current := v.timestampUnit.since(v.clock.Now())
+	diffTime := current - timestamp
+	if diffTime >= int64(v.maxAge/unit) {
+		return nil, ErrTimestampExpired
+	}
+	if -diffTime > int64(v.clockSkew/unit) {
+		return nil, ErrTimestampInFuture
+	}
+
+	// During a key rotation the sender may send one signature per key,
+	// separated by commas.
+	var decoded [][]byte
+	for _, entry := range strings.Split(signature, ",") {
+		encoded, err := stripAlgorithmPrefix(strings.TrimSpace(entry), v.algorithm())
+		if err != nil {
+			return nil, err
+		}
+		if encoded == "" {
+			return nil, ErrMalformedSignature
+		}
+		d, err := v.encoding.decode(encoded)
+		if err != nil {
+			return nil, ErrMalformedSignature
+		}
+		decoded = append(decoded, d)
+	}
+
+	version := headers.Get(versionHeader)
+	if version != "" && !v.supportsVersion(version) {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedVersion, version)
+	}
+	return &requestSignature{
+		signature: signature,
+		decoded:   decoded,
+		timestamp: tsStr,
+		version:   version,
+		drift:     time.Duration(diffTime) * unit,
+	}, nil
+}
+
+// finish checks the signatures with check and checks for replays. It
+// returns the index of the first matching key.
+func (v *Verifier) finish(ctx context.Context, headers http.Header, body []byte, sig *requestSignature, check signatureCheck) (int, error) {
+	matched, mac := check.match(body, sig.decoded)
+	if matched == -1 {
+		return -1, ErrSignatureMismatch
+	}
+
+	// Ignored events are acknowledged without using replay or dedupe
+	// entries, so their retries are acknowledged the same way.
+	if v.allowedTypes != nil {
+		if eventType, ok := peekEventType(body); ok && !v.allowedTypes[eventType] {
+			v.ignore(eventType)
+			return -1, ErrEventIgnored
+		}
+	}
+
+	// Replays are only tracked once the signature is known to be valid, so
+	// forged requests cannot fill the store. A delivery stays acceptable
+	// from clockSkew before its timestamp until maxAge after it.
+	// The delivery is reserved now, atomically with a ReplayReserver, so
+	// concurrent copies are rejected, and Verifier.Release forgets it again
+	// if handling it fails.
+	d := deliveryFromContext(ctx)
+	if v.replay != nil {
+		id := deliveryID(sig.timestamp, mac)
+		if d != nil {
+			d.id = id
+		}
+		if !reserve(ctx, v.replay, id, v.maxAge+v.clockSkew) {
+			return -1, ErrReplayDetected
+		}
+		if d != nil {
+			d.reserved = true
+		}
+	}
+	if v.dedupe != nil {
+		id := payloadID(body)
+		if !reserve(ctx, v.dedupe, id, v.dedupeTTL) {
+			return -1, ErrDuplicateEvent
+		}
+		if d != nil {
+			d.payload = id
+		}
+	}
+	v.observeVerified(headers, sig.drift)
+	return matched, nil
+}
+
+// observeVerified records when a webhook of the tenant named by headers was
+// verified and reports its drift.
+func (v *Verifier) observeVerified(headers http.Header, drift time.Duration) {
+	var tenant string
+	if p, ok := v.keyProvider.(TenantKeyProvider); ok {
+		tenant = p.Tenant(headers)
+	}
+	now := v.clock.Now()
+	v.lastSeen.record(tenant, now)
+	if v.lastSeenMetrics != nil {
+		v.lastSeenMetrics.SetLastSeen(tenant, now)
+	}
+	if v.driftMetrics != nil {
+		v.driftMetrics.ObserveDrift(tenant, drift)
+	}
+	if v.onDrift != nil {
+		v.onDrift(tenant, drift)
+	}
+}
+
+// Ready reports whether v can verify webhooks: it has a non-empty key or a
+// KeyProvider and, if its replay store implements Pinger, the store is
+// reachable.
+func (v *Verifier) Ready(ctx context.Context) error {
+	if keys := v.keys.Load(); v.keyProvider == nil && (keys == nil || len(keys.keys) == 0) {
+		return errors.New("webhook: no api key configured")
+	}
+	if p, ok := v.replay.(Pinger); ok {
+		if err := p.Ping(ctx); err != nil {
+			return fmt.Errorf("webhook: replay store unreachable: %w", err)
+		}
+	}
+	return nil
+}
+
+// peekEventType returns the type of the single event in body without
+// decoding its data. It reports false for batches and bodies that are not
+// JSON objects, whose type is only known once parsed.
+func peekEventType(body []byte) (EventType, bool) {
+	var envelope struct {
+		Event EventType `json:"event"`
+	}
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] != '{' || json.Unmarshal(trimmed, &envelope) != nil {
+		return "", false
+	}
+	return envelope.Event, true
+}
+
+// ignore counts an event ignored because of WithAllowedEventTypes.
+func (v *Verifier) ignore(eventType EventType) {
+	if v.ignoredMetrics != nil {
+		v.ignoredMetrics.IncIgnored(string(eventType))
+	}
+}
@@ -0,0 +1,114 @@
+// Package webhooktest provides a fake OpenVidu Meet webhook sender for
+// testing webhook consumers.
+package webhooktest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/webhook"
+)
+
+// DefaultURL is the URL MockSender builds requests for unless its URL is
+// set.
+const DefaultURL = "http://localhost/webhook"
+
+// MockSender builds webhook requests signed the way OpenVidu Meet signs
+// them. The requests can be passed to a handler directly or sent to an
+// httptest.Server with an http.Client.
+type MockSender struct {
+	// Key is the API key requests are signed with.
+	Key string
+	// URL is the URL requests are built for. The default is DefaultURL.
+	URL string
+	// Now returns the time requests are signed at. The default is
+	// time.Now.
+	Now func() time.Time
+}
+
+// NewMockSender returns a MockSender that signs requests with key.
+func NewMockSender(key string) *MockSender {
+	return &MockSender{Key: key}
+}
+
+// Event returns the body of a webhook of type eventType whose payload is
+// data marshaled as JSON, created at the sender's current time. It panics
+// if data cannot be marshaled.
+func (s *MockSender) Event(eventType webhook.EventType, data any) []byte {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		panic("webhooktest: marshal event data: " + err.Error())
+	}
+	body, err := json.Marshal(webhook.WebhookEvent{
+		Type:         eventType,
+		CreationDate: s.now().UnixMilli(),
+		Data:         payload,
+	})
+	if err != nil {
+		panic("webhooktest: marshal event: " + err.Error())
+	}
+	return body
+}
+
+// Request returns a request carrying body, signed with the sender's key at
+// its current time.
+func (s *MockSender) Request(body []byte) *http.Request {
+	return s.RequestAt(body, s.now())
+}
+
+// RequestAt is like Request but signs the request at ts.
+func (s *MockSender) RequestAt(body []byte, ts time.Time) *http.Request {
+	return s.build(body, body, s.Key, ts)
+}
+
+// ExpiredRequest returns a request signed longer than
+// webhook.DefaultMaxAge ago, which a Verifier with the default max age
+// rejects with webhook.ErrTimestampExpired.
+func (s *MockSender) ExpiredRequest(body []byte) *http.Request {
+	return s.RequestAt(body, s.now().Add(-webhook.DefaultMaxAge-time.Second))
+}
+
+// WrongKeyRequest returns a request signed with a key other than the
+// sender's, which is rejected with webhook.ErrSignatureMismatch.
+func (s *MockSender) WrongKeyRequest(body []byte) *http.Request {
+	return s.build(body, body, s.Key+"-wrong", s.now())
+}
+
+// TamperedRequest returns a request whose body was changed after signing,
+// which is rejected with webhook.ErrSignatureMismatch. Only trailing
+// whitespace is added, so a JSON body still parses and the rejection is
+// caused by the signature alone.
+func (s *MockSender) TamperedRequest(body []byte) *http.Request {
+	return s.build(body, append(bytes.Clone(body), ' '), s.Key, s.now())
+}
+
+// build returns a request carrying sent, with the signature of signed
+// computed with key at ts.
+func (s *MockSender) build(signed, sent []byte, key string, ts time.Time) *http.Request {
+	url := s.URL
+	if url == "" {
+		url = DefaultURL
+	}
+	r, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(sent))
+	if err != nil {
+		panic("webhooktest: " + err.Error())
+	}
+
+	signature, timestamp := webhook.Sign(key, signed, ts)
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(webhook.DefaultSignatureHeader, signature)
+	r.Header.Set(webhook.DefaultTimestampHeader, timestamp)
+	// Handlers called directly see the request as coming from this
+	// address, as with httptest.NewRequest. Clients ignore it.
+	r.RemoteAddr = "192.0.2.1:1234"
+	return r
+}
+
+func (s *MockSender) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,80 @@
+package webhooktest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/OpenVidu/openvidu-meet/webhooks-snippets/go/webhook"
+)
+
+const testKey = "test-api-key"
+
+func TestMockSender(t *testing.T) {
+	v, err := webhook.NewVerifier(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewMockSender(testKey)
+	body := s.Event(webhook.EventMeetingStarted, webhook.Room{RoomID: "room-1", RoomName: "Room 1"})
+
+	event, _, err := v.ReadRequest(s.Request(body))
+	if err != nil {
+		t.Fatalf("ReadRequest() = %v, want nil", err)
+	}
+	if event.Type != webhook.EventMeetingStarted || event.RoomID != "room-1" {
+		t.Errorf("ReadRequest() event = %s for %q, want %s for %q", event.Type, event.RoomID, webhook.EventMeetingStarted, "room-1")
+	}
+
+	tests := map[string]struct {
+		r    *http.Request
+		want error
+	}{
+		"expired":   {s.ExpiredRequest(body), webhook.ErrTimestampExpired},
+		"wrong key": {s.WrongKeyRequest(body), webhook.ErrSignatureMismatch},
+		"tampered":  {s.TamperedRequest(body), webhook.ErrSignatureMismatch},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := v.ReadRequest(tt.r); !errors.Is(err, tt.want) {
+				t.Errorf("ReadRequest() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMockSenderServer(t *testing.T) {
+	v, err := webhook.NewVerifier(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(webhook.Handler(v, func(w http.ResponseWriter, r *http.Request, event *webhook.WebhookEvent) {
+		io.WriteString(w, string(event.Type))
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	s := &MockSender{Key: testKey, URL: srv.URL, Now: func() time.Time { return now }}
+	body := s.Event(webhook.EventRoomClosed, webhook.RoomPayload{RoomID: "room-1"})
+
+	resp, err := srv.Client().Do(s.Request(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusOK || string(got) != string(webhook.EventRoomClosed) {
+		t.Errorf("response = %d %q, want 200 %q", resp.StatusCode, got, webhook.EventRoomClosed)
+	}
+
+	resp, err = srv.Client().Do(s.WrongKeyRequest(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong key status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+}